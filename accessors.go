@@ -0,0 +1,419 @@
+package xvals
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// accessors implements the value accessors shared by a Context and its
+// views. Keys are looked up in ctx, prefixed with prefix.
+type accessors struct {
+	ctx    *Context
+	prefix string
+}
+
+// get returns the expanded value of key and its origin
+func (a *accessors) get(key string) (string, Origin, error) {
+	return a.ctx.lookup(a.prefix + key)
+}
+
+// parse looks up key and parses its value as a t, with the parser
+// registered for t or else with fn. Parse failures are returned as
+// *ParseError, naming the key and the provider of the value.
+func (a *accessors) parse(key string, t reflect.Type, fn func(string) (interface{}, error)) (interface{}, error) {
+	s, o, err := a.get(key)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if rfn, ok := registeredParser(t); ok {
+		var rv reflect.Value
+		if rv, err = parseRegistered(rfn, t, s); err == nil {
+			v = rv.Interface()
+		}
+	} else {
+		v, err = fn(s)
+	}
+	if err != nil {
+		return nil, parseError(a.prefix+key, o, err)
+	}
+	return v, nil
+}
+
+// parseAs is parse for values of type T
+func parseAs[T any](a *accessors, key string, fn func(string) (T, error)) (T, error) {
+	v, err := a.parse(key, typeOf[T](), func(s string) (interface{}, error) { return fn(s) })
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// typeOf returns the reflect.Type of T, which may be an interface type
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// byteSize is the type of sizes parsed by SizeValue, separate from uint64 so
+// that a parser registered for uint64 doesn't apply to sizes
+type byteSize uint64
+
+// ParsedValue fetches and parses a value as a t, with the parser registered
+// for t, see RegisterParser, or as Bind would parse it. Returns an
+// interface{} holding a t.
+func (a *accessors) ParsedValue(key string, t reflect.Type) (interface{}, error) {
+	return a.parse(key, t, func(s string) (interface{}, error) {
+		v := reflect.New(t).Elem()
+		if err := setValue(v, s); err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	})
+}
+
+// HasValue returns true if the value exist
+func (a *accessors) HasValue(key string) bool {
+	_, e := a.Value(key)
+	return e == nil
+}
+
+// Value returns the value as a string, with references to other keys
+// expanded. An error is returned if the value didn't exist or couldn't be
+// expanded.
+func (a *accessors) Value(key string) (string, error) {
+	v, _, err := a.get(key)
+	return v, err
+}
+
+// ValueD retrieves a value. If it doesn't exist it will return defaultVal
+// instead
+func (a *accessors) ValueD(key, defaultVal string) string {
+	v, e := a.Value(key)
+	if e != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// BoolValue is a convenience function to fetch and parse
+// a value as a boolean. true, yes, on and 1 are true, false, no, off and 0
+// are false, ignoring case. Parse failures are returned as *ParseError.
+func (a *accessors) BoolValue(key string) (val bool, err error) {
+	return parseAs(a, key, parseBool)
+}
+
+// BoolValueD return values as bool, or return defaultVal, if it doesn't exist
+func (a *accessors) BoolValueD(key string, defaultVal bool) bool {
+	b, e := a.BoolValue(key)
+	if e != nil {
+		return defaultVal
+	}
+	return b
+}
+
+// IntValue is a convenience function to fetch and parse
+// a value as an int. Parse failures are returned as *ParseError.
+func (a *accessors) IntValue(key string) (val int, err error) {
+	return parseAs(a, key, strconv.Atoi)
+}
+
+// IntValueD return value as int, or defaultVal if non-existent.
+func (a *accessors) IntValueD(key string, defaultVal int) int {
+	v, e := a.IntValue(key)
+	if e != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// DurationValue fetches and parses a value as a duration, like "1m30s".
+func (a *accessors) DurationValue(key string) (time.Duration, error) {
+	return parseAs(a, key, time.ParseDuration)
+}
+
+// DurationValueD returns the value as time.Duration, or defaultVal if it
+// doesn't exist or can't be parsed.
+func (a *accessors) DurationValueD(key string, defaultVal time.Duration) time.Duration {
+	v, err := a.DurationValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// FloatValue fetches and parses a value as a float64.
+func (a *accessors) FloatValue(key string) (float64, error) {
+	return parseAs(a, key, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+}
+
+// FloatValueD returns the value as float64, or defaultVal if it doesn't
+// exist or can't be parsed.
+func (a *accessors) FloatValueD(key string, defaultVal float64) float64 {
+	v, err := a.FloatValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// Int64Value fetches and parses a value as an int64. Hexadecimal, octal and
+// binary values are accepted with the 0x, 0o and 0b prefixes.
+func (a *accessors) Int64Value(key string) (int64, error) {
+	return parseAs(a, key, func(s string) (int64, error) { return strconv.ParseInt(s, 0, 64) })
+}
+
+// Int64ValueD returns the value as int64, or defaultVal if it doesn't exist
+// or can't be parsed.
+func (a *accessors) Int64ValueD(key string, defaultVal int64) int64 {
+	v, err := a.Int64Value(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// Uint64Value fetches and parses a value as an uint64. Hexadecimal, octal
+// and binary values are accepted with the 0x, 0o and 0b prefixes.
+func (a *accessors) Uint64Value(key string) (uint64, error) {
+	return parseAs(a, key, func(s string) (uint64, error) { return strconv.ParseUint(s, 0, 64) })
+}
+
+// Uint64ValueD returns the value as uint64, or defaultVal if it doesn't
+// exist or can't be parsed.
+func (a *accessors) Uint64ValueD(key string, defaultVal uint64) uint64 {
+	v, err := a.Uint64Value(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// SizeValue fetches and parses a value as a size in bytes, like "512", "10k"
+// or "64MiB". SI units (k, KB, M, MB, ...) are powers of 1000 and IEC units
+// (Ki, KiB, Mi, MiB, ...) powers of 1024.
+func (a *accessors) SizeValue(key string) (uint64, error) {
+	v, err := parseAs(a, key, func(s string) (byteSize, error) {
+		n, err := parseSize(s)
+		return byteSize(n), err
+	})
+	return uint64(v), err
+}
+
+// SizeValueD returns the value as uint64, or defaultVal if it doesn't exist
+// or can't be parsed.
+func (a *accessors) SizeValueD(key string, defaultVal uint64) uint64 {
+	v, err := a.SizeValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// URLValue fetches and parses a value as an absolute URL.
+func (a *accessors) URLValue(key string) (*url.URL, error) {
+	return parseAs(a, key, parseURL)
+}
+
+// URLValueD returns the value as *url.URL, or defaultVal if it doesn't exist
+// or can't be parsed.
+func (a *accessors) URLValueD(key string, defaultVal *url.URL) *url.URL {
+	v, err := a.URLValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// IPValue fetches and parses a value as an IPv4 or IPv6 address.
+func (a *accessors) IPValue(key string) (net.IP, error) {
+	return parseAs(a, key, parseIP)
+}
+
+// IPValueD returns the value as net.IP, or defaultVal if it doesn't exist or
+// can't be parsed.
+func (a *accessors) IPValueD(key string, defaultVal net.IP) net.IP {
+	v, err := a.IPValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// CIDRValue fetches and parses a value as a network in CIDR notation, like
+// "10.0.0.0/8".
+func (a *accessors) CIDRValue(key string) (*net.IPNet, error) {
+	return parseAs(a, key, parseCIDR)
+}
+
+// CIDRValueD returns the value as *net.IPNet, or defaultVal if it doesn't
+// exist or can't be parsed.
+func (a *accessors) CIDRValueD(key string, defaultVal *net.IPNet) *net.IPNet {
+	v, err := a.CIDRValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// TimeValue fetches and parses a value as a time in RFC 3339 format, like
+// "2006-01-02T15:04:05Z07:00", or a date, like "2006-01-02". A time without
+// time zone is UTC.
+func (a *accessors) TimeValue(key string) (time.Time, error) {
+	return parseAs(a, key, parseTime)
+}
+
+// TimeValueD returns the value as time.Time, or defaultVal if it doesn't
+// exist or can't be parsed.
+func (a *accessors) TimeValueD(key string, defaultVal time.Time) time.Time {
+	v, err := a.TimeValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// ListValue fetches and parses a value as a comma separated list. Items are
+// trimmed, and commas in items are escaped as \,.
+func (a *accessors) ListValue(key string) ([]string, error) {
+	return parseAs(a, key, func(s string) ([]string, error) { return splitList(s), nil })
+}
+
+// ListValueD returns the value as []string, or defaultVal if it doesn't
+// exist or can't be parsed.
+func (a *accessors) ListValueD(key string, defaultVal []string) []string {
+	v, err := a.ListValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// MapValue fetches and parses a value as comma separated key=value pairs,
+// like "a=1,b=2". Commas in values are escaped as \,.
+func (a *accessors) MapValue(key string) (map[string]string, error) {
+	return parseAs(a, key, parseMap)
+}
+
+// MapValueD returns the value as map[string]string, or defaultVal if it
+// doesn't exist or can't be parsed.
+func (a *accessors) MapValueD(key string, defaultVal map[string]string) map[string]string {
+	v, err := a.MapValue(key)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
+// DurationValue fetches a value of the default context as a duration.
+func DurationValue(key string) (time.Duration, error) {
+	return defaultContext.DurationValue(key)
+}
+
+// DurationValueD is DurationValue, returning defaultVal on errors.
+func DurationValueD(key string, defaultVal time.Duration) time.Duration {
+	return defaultContext.DurationValueD(key, defaultVal)
+}
+
+// FloatValue fetches a value of the default context as a float64.
+func FloatValue(key string) (float64, error) {
+	return defaultContext.FloatValue(key)
+}
+
+// FloatValueD is FloatValue, returning defaultVal on errors.
+func FloatValueD(key string, defaultVal float64) float64 {
+	return defaultContext.FloatValueD(key, defaultVal)
+}
+
+// Int64Value fetches a value of the default context as an int64.
+func Int64Value(key string) (int64, error) {
+	return defaultContext.Int64Value(key)
+}
+
+// Int64ValueD is Int64Value, returning defaultVal on errors.
+func Int64ValueD(key string, defaultVal int64) int64 {
+	return defaultContext.Int64ValueD(key, defaultVal)
+}
+
+// Uint64Value fetches a value of the default context as an uint64.
+func Uint64Value(key string) (uint64, error) {
+	return defaultContext.Uint64Value(key)
+}
+
+// Uint64ValueD is Uint64Value, returning defaultVal on errors.
+func Uint64ValueD(key string, defaultVal uint64) uint64 {
+	return defaultContext.Uint64ValueD(key, defaultVal)
+}
+
+// SizeValue fetches a value of the default context as a byte size.
+func SizeValue(key string) (uint64, error) {
+	return defaultContext.SizeValue(key)
+}
+
+// SizeValueD is SizeValue, returning defaultVal on errors.
+func SizeValueD(key string, defaultVal uint64) uint64 {
+	return defaultContext.SizeValueD(key, defaultVal)
+}
+
+// URLValue fetches a value of the default context as an URL.
+func URLValue(key string) (*url.URL, error) {
+	return defaultContext.URLValue(key)
+}
+
+// URLValueD is URLValue, returning defaultVal on errors.
+func URLValueD(key string, defaultVal *url.URL) *url.URL {
+	return defaultContext.URLValueD(key, defaultVal)
+}
+
+// IPValue fetches a value of the default context as an IP address.
+func IPValue(key string) (net.IP, error) {
+	return defaultContext.IPValue(key)
+}
+
+// IPValueD is IPValue, returning defaultVal on errors.
+func IPValueD(key string, defaultVal net.IP) net.IP {
+	return defaultContext.IPValueD(key, defaultVal)
+}
+
+// CIDRValue fetches a value of the default context as a network.
+func CIDRValue(key string) (*net.IPNet, error) {
+	return defaultContext.CIDRValue(key)
+}
+
+// CIDRValueD is CIDRValue, returning defaultVal on errors.
+func CIDRValueD(key string, defaultVal *net.IPNet) *net.IPNet {
+	return defaultContext.CIDRValueD(key, defaultVal)
+}
+
+// TimeValue fetches a value of the default context as a time.
+func TimeValue(key string) (time.Time, error) {
+	return defaultContext.TimeValue(key)
+}
+
+// TimeValueD is TimeValue, returning defaultVal on errors.
+func TimeValueD(key string, defaultVal time.Time) time.Time {
+	return defaultContext.TimeValueD(key, defaultVal)
+}
+
+// ListValue fetches a value of the default context as a list.
+func ListValue(key string) ([]string, error) {
+	return defaultContext.ListValue(key)
+}
+
+// ListValueD is ListValue, returning defaultVal on errors.
+func ListValueD(key string, defaultVal []string) []string {
+	return defaultContext.ListValueD(key, defaultVal)
+}
+
+// MapValue fetches a value of the default context as a map.
+func MapValue(key string) (map[string]string, error) {
+	return defaultContext.MapValue(key)
+}
+
+// MapValueD is MapValue, returning defaultVal on errors.
+func MapValueD(key string, defaultVal map[string]string) map[string]string {
+	return defaultContext.MapValueD(key, defaultVal)
+}
@@ -0,0 +1,146 @@
+package xvals
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedAccessors(t *testing.T) {
+	c := NewContext()
+	c.WithMap(map[string]string{
+		"timeout":  "1m30s",
+		"ratio":    "0.25",
+		"big":      "-9000000000",
+		"mask":     "0xff",
+		"cache":    "64MiB",
+		"disk":     "1.5GB",
+		"api":      "https://example.com:8443/v1",
+		"relative": "/v1",
+		"ip":       "::1",
+		"net":      "10.0.0.0/8",
+		"at":       "2024-03-01T12:00:00+01:00",
+		"day":      "2024-03-01",
+		"hosts":    "a, b\\,c ,d",
+		"labels":   "app=web, tier = front",
+	})
+	check := func(key string, got interface{}, err error, exp string) {
+		if err != nil || fmt.Sprint(got) != exp {
+			t.Logf("key: [%s] got:[%v] [%v] expected: [%s]", key, got, err, exp)
+			t.FailNow()
+		}
+	}
+	d, err := c.DurationValue("timeout")
+	check("timeout", d, err, "1m30s")
+	f, err := c.FloatValue("ratio")
+	check("ratio", f, err, "0.25")
+	i, err := c.Int64Value("big")
+	check("big", i, err, "-9000000000")
+	u, err := c.Uint64Value("mask")
+	check("mask", u, err, "255")
+	s, err := c.SizeValue("cache")
+	check("cache", s, err, "67108864")
+	s, err = c.SizeValue("disk")
+	check("disk", s, err, "1500000000")
+	api, err := c.URLValue("api")
+	check("api", api, err, "https://example.com:8443/v1")
+	ip, err := c.IPValue("ip")
+	check("ip", ip, err, "::1")
+	n, err := c.CIDRValue("net")
+	check("net", n, err, "10.0.0.0/8")
+	at, err := c.TimeValue("at")
+	check("at", at.UTC(), err, "2024-03-01 11:00:00 +0000 UTC")
+	day, err := c.TimeValue("day")
+	check("day", day, err, "2024-03-01 00:00:00 +0000 UTC")
+	l, err := c.ListValue("hosts")
+	check("hosts", fmt.Sprintf("%q", l), err, `["a" "b,c" "d"]`)
+	m, err := c.MapValue("labels")
+	check("labels", m, err, "map[app:web tier:front]")
+
+	_, err = c.URLValue("relative")
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Key != "relative" || pe.Provider != "map (priority 100)" {
+		t.Logf("expected a ParseError for relative, got %v", err)
+		t.FailNow()
+	}
+	if _, err := c.SizeValue("ratio"); err != nil {
+		t.Logf("expected a fractional size to be rounded down, got %v", err)
+		t.FailNow()
+	}
+	if _, err := c.SizeValue("timeout"); !errors.As(err, &pe) {
+		t.Logf("expected a ParseError for timeout as a size, got %v", err)
+		t.FailNow()
+	}
+	if v := c.DurationValueD("missing", time.Second); v != time.Second {
+		t.Logf("expected the default, got %v", v)
+		t.FailNow()
+	}
+	if v := c.Sub("no").SizeValueD("such", 1); v != 1 {
+		t.Logf("expected the default, got %v", v)
+		t.FailNow()
+	}
+}
+
+type testLevel int
+
+func TestRegisterParser(t *testing.T) {
+	levelType := reflect.TypeOf(testLevel(0))
+	RegisterParser(levelType, func(s string) (interface{}, error) {
+		switch strings.ToLower(s) {
+		case "debug":
+			return testLevel(0), nil
+		case "info":
+			return testLevel(1), nil
+		}
+		return nil, fmt.Errorf("unknown level %q", s)
+	})
+	defer RegisterParser(levelType, nil)
+	RegisterParser(reflect.TypeOf(time.Duration(0)), func(s string) (interface{}, error) {
+		n, err := strconv.Atoi(s)
+		return time.Duration(n) * time.Second, err
+	})
+	defer RegisterParser(reflect.TypeOf(time.Duration(0)), nil)
+
+	c := NewContext()
+	c.WithMap(map[string]string{"level": "INFO", "bad": "loud", "timeout": "5", "on": "On", "api": "http://h/"})
+	v, err := c.ParsedValue("level", levelType)
+	if err != nil || v.(testLevel) != 1 {
+		t.Logf("expected level 1, got %v %v", v, err)
+		t.FailNow()
+	}
+	var pe *ParseError
+	if _, err := c.ParsedValue("bad", levelType); !errors.As(err, &pe) {
+		t.Logf("expected a ParseError, got %v", err)
+		t.FailNow()
+	}
+	if d, err := c.DurationValue("timeout"); err != nil || d != 5*time.Second {
+		t.Logf("expected the registered duration parser to be used, got %v %v", d, err)
+		t.FailNow()
+	}
+	if b, err := c.BoolValue("on"); err != nil || !b {
+		t.Logf("expected On to be true, got %v %v", b, err)
+		t.FailNow()
+	}
+
+	var cfg struct {
+		Level   testLevel     `xvals:"level"`
+		Timeout time.Duration `xvals:"timeout"`
+		On      bool          `xvals:"on"`
+		API     *url.URL      `xvals:"api"`
+	}
+	if err := c.Bind(&cfg); err != nil || cfg.Level != 1 || cfg.Timeout != 5*time.Second || !cfg.On || cfg.API.Host != "h" {
+		t.Logf("unexpected bind %+v %v", cfg, err)
+		t.FailNow()
+	}
+
+	RegisterParser(levelType, func(s string) (interface{}, error) { return 1, nil })
+	if _, err := c.ParsedValue("level", levelType); err == nil {
+		t.Logf("expected an error for a parser returning the wrong type")
+		t.FailNow()
+	}
+}
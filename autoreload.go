@@ -0,0 +1,298 @@
+package xvals
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A fileProvider reads its values from a file or a directory, which can be
+// watched for changes.
+type fileProvider interface {
+	Refresher
+
+	// watchTarget returns the file or directory the values are read from
+	watchTarget() (path string, isDir bool)
+
+	// reload the values, keeping the previous values on errors
+	reload() error
+}
+
+// WatchOption configures AutoReload
+type WatchOption func(*autoReloader)
+
+// PollInterval sets how often files are checked for changes when they are
+// polled. The default is one second.
+func PollInterval(d time.Duration) WatchOption {
+	return func(a *autoReloader) {
+		a.interval = d
+	}
+}
+
+// Debounce sets how long to wait for more changes before reloading, so that
+// a burst of writes to a file only causes one reload. The default is 100ms.
+func Debounce(d time.Duration) WatchOption {
+	return func(a *autoReloader) {
+		a.debounce = d
+	}
+}
+
+// ForcePolling makes AutoReload poll the files for changes, even where file
+// system notifications are available. Useful for network file systems,
+// which often don't deliver notifications.
+func ForcePolling() WatchOption {
+	return func(a *autoReloader) {
+		a.poll = true
+	}
+}
+
+// OnReloadError sets a function called when a provider fails to reload, or
+// when the objects fail to reload, in which case p is nil. The provider keeps
+// its previous values. By default the error is logged.
+func OnReloadError(fn func(p XvalProvider, err error)) WatchOption {
+	return func(a *autoReloader) {
+		a.onError = fn
+	}
+}
+
+// watchTarget is a file or directory watched for a provider
+type watchTarget struct {
+	p     fileProvider
+	path  string
+	isDir bool
+}
+
+// matches returns true if a change of name in dir concerns the target. An
+// empty name is a change of dir itself.
+func (t *watchTarget) matches(dir, name string) bool {
+	if t.isDir {
+		return dir == t.path
+	}
+	// ..data is swapped by Kubernetes when a mounted file is updated
+	return dir == filepath.Dir(t.path) && (name == "" || name == filepath.Base(t.path) || name == "..data")
+}
+
+// autoReloader watches the files of the providers of a context
+type autoReloader struct {
+	c        *Context
+	interval time.Duration
+	debounce time.Duration
+	poll     bool
+	onError  func(XvalProvider, error)
+	targets  []*watchTarget
+	changed  chan fileProvider
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// AutoReload watches the files and directories of the file backed providers
+// of the context, and reloads a provider when its files change. After the
+// changed providers are reloaded the objects are reloaded, and watchers
+// notified, by ReloadObjects. A provider that fails to reload keeps its
+// previous values, and the failure is reported to the OnReloadError function.
+//
+// File system notifications are used where available, otherwise the files
+// are polled. Providers added after AutoReload is called are not watched.
+// The returned function stops watching.
+func (c *Context) AutoReload(opts ...WatchOption) (stop func(), err error) {
+	a := &autoReloader{
+		c:        c,
+		interval: time.Second,
+		debounce: 100 * time.Millisecond,
+		changed:  make(chan fileProvider),
+		stop:     make(chan struct{}),
+	}
+	a.onError = func(p XvalProvider, err error) {
+		if p == nil {
+			c.Logger().Error("failed to reload objects", "error", err)
+			return
+		}
+		logReloadError(c.Logger(), p, err)
+	}
+	for _, o := range opts {
+		o(a)
+	}
+	for _, p := range c.chain() {
+		fp, ok := p.(fileProvider)
+		if !ok {
+			continue
+		}
+		path, isDir := fp.watchTarget()
+		if path, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+		a.targets = append(a.targets, &watchTarget{p: fp, path: path, isDir: isDir})
+	}
+
+	closeWatcher := func() {}
+	if !a.poll {
+		// fall back to polling when notifications can't be used
+		if cw, nerr := a.notify(); nerr != nil {
+			a.poll = true
+		} else {
+			closeWatcher = cw
+		}
+	}
+	if a.poll {
+		// signatures are taken before returning, so that changes made
+		// right after AutoReload returns are seen
+		sigs := make([]string, len(a.targets))
+		for i, t := range a.targets {
+			sigs[i] = signature(t)
+		}
+		a.wg.Add(1)
+		go a.pollFiles(sigs)
+	}
+	a.wg.Add(1)
+	go a.run()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			close(a.stop)
+			closeWatcher()
+			a.wg.Wait()
+		})
+	}, nil
+}
+
+// dirs returns the directories to watch for file system notifications.
+func (a *autoReloader) dirs() []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, t := range a.targets {
+		dir := t.path
+		if !t.isDir {
+			dir = filepath.Dir(t.path)
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			res = append(res, dir)
+		}
+	}
+	return res
+}
+
+// changedIn reports the providers affected by a change of name in dir.
+func (a *autoReloader) changedIn(dir, name string) {
+	for _, t := range a.targets {
+		if t.matches(dir, name) {
+			select {
+			case a.changed <- t.p:
+			case <-a.stop:
+				return
+			}
+		}
+	}
+}
+
+// run collects changed providers and reloads them when no more changes
+// have arrived for the debounce duration.
+func (a *autoReloader) run() {
+	defer a.wg.Done()
+	dirty := make(map[fileProvider]bool)
+	var (
+		timer  *time.Timer
+		timerC <-chan time.Time
+	)
+	for {
+		select {
+		case p := <-a.changed:
+			dirty[p] = true
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(a.debounce)
+			timerC = timer.C
+		case <-timerC:
+			timer, timerC = nil, nil
+			for _, t := range a.targets {
+				if !dirty[t.p] {
+					continue
+				}
+				if err := t.p.Refresh(); err != nil {
+					a.onError(t.p, err)
+				} else {
+					a.c.Logger().Debug("reloaded provider", logArgs(t.p)...)
+				}
+			}
+			dirty = make(map[fileProvider]bool)
+			if err := a.c.ReloadObjects(); err != nil {
+				a.onError(nil, err)
+			}
+		case <-a.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// pollFiles checks the targets for changes every interval. sigs are the
+// signatures of the targets when polling started.
+func (a *autoReloader) pollFiles(sigs []string) {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for i, t := range a.targets {
+				sig := signature(t)
+				if sig == sigs[i] {
+					continue
+				}
+				sigs[i] = sig
+				select {
+				case a.changed <- t.p:
+				case <-a.stop:
+					return
+				}
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// signature returns a string that changes when the content of the target is
+// likely to have changed.
+func signature(t *watchTarget) string {
+	if !t.isDir {
+		return statSignature(t.path)
+	}
+	entries, err := os.ReadDir(t.path)
+	if err != nil {
+		return err.Error()
+	}
+	sigs := make([]string, 0, len(entries)+1)
+	for _, e := range entries {
+		sigs = append(sigs, e.Name()+" "+statSignature(filepath.Join(t.path, e.Name())))
+	}
+	// the target of ..data changes when Kubernetes updates the directory
+	if link, err := os.Readlink(filepath.Join(t.path, "..data")); err == nil {
+		sigs = append(sigs, link)
+	}
+	sort.Strings(sigs)
+	return strings.Join(sigs, "\n")
+}
+
+// statSignature returns the size, modification time and mode of path
+func statSignature(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%d %d %v", fi.Size(), fi.ModTime().UnixNano(), fi.Mode())
+}
+
+// AutoReload watches the files of the file backed providers of the default
+// context and reloads them when they change.
+func AutoReload(opts ...WatchOption) (stop func(), err error) {
+	return defaultContext.AutoReload(opts...)
+}
@@ -0,0 +1,54 @@
+package xvals
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// notify watches the directories of the targets with inotify. Directories
+// rather than files are watched, so that files replaced by a rename, as most
+// editors and Kubernetes do, are still seen. Returns a function that stops
+// the watching.
+func (a *autoReloader) notify() (func(), error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// A non blocking file is handled by the runtime poller, so closing it
+	// unblocks a pending Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	const mask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+		syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+	dirs := make(map[int32]string)
+	for _, dir := range a.dirs() {
+		wd, err := syscall.InotifyAddWatch(fd, dir, mask)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		dirs[int32(wd)] = dir
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				start := off + syscall.SizeofInotifyEvent
+				name := strings.TrimRight(string(buf[start:start+int(ev.Len)]), "\x00")
+				off = start + int(ev.Len)
+				if dir, ok := dirs[ev.Wd]; ok {
+					a.changedIn(dir, name)
+				}
+			}
+		}
+	}()
+	return func() { f.Close() }, nil
+}
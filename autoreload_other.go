@@ -0,0 +1,10 @@
+//go:build !linux
+
+package xvals
+
+import "errors"
+
+// notify is not available on this platform, the files are polled instead.
+func (a *autoReloader) notify() (func(), error) {
+	return nil, errors.New("file system notifications not supported")
+}
@@ -0,0 +1,104 @@
+package xvals
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testAutoReload(t *testing.T, opts ...WatchOption) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cfg.yaml")
+	os.WriteFile(file, []byte("ep_api_address: localhost:1\n"), 0o644)
+
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithConfigFile(file)
+	c.ReloadObjects()
+
+	changed := make(chan string, 10)
+	c.Watch("ep_api_address", func(old, new string) { changed <- new })
+	failed := make(chan error, 10)
+	opts = append(opts, Debounce(20*time.Millisecond), PollInterval(10*time.Millisecond),
+		OnReloadError(func(p XvalProvider, err error) { failed <- err }))
+	stop, err := c.AutoReload(opts...)
+	if err != nil {
+		t.Logf("failed to start auto reload %v", err)
+		t.FailNow()
+	}
+	defer stop()
+
+	// a burst of writes results in one reload
+	for i := 0; i < 5; i++ {
+		os.WriteFile(file, []byte("ep_api_address: localhost:2\n"), 0o644)
+	}
+	select {
+	case v := <-changed:
+		if v != "localhost:2" {
+			t.Logf("got:[%s] expected: [localhost:2]", v)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Logf("no reload after the file changed")
+		t.FailNow()
+	}
+	if ep, e := c.GetEndpoint("api"); e != nil || ep.Address != "localhost:2" {
+		t.Logf("objects not reloaded %v %v", ep, e)
+		t.FailNow()
+	}
+
+	// a broken file keeps the last good values
+	os.WriteFile(file, []byte("ep_api_address: [unterminated\n"), 0o644)
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Logf("no error reported for broken file")
+		t.FailNow()
+	}
+	GetGoodC(t, c, "ep_api_address", "localhost:2")
+	select {
+	case v := <-changed:
+		t.Logf("unexpected change to %s", v)
+		t.FailNow()
+	default:
+	}
+}
+
+func TestAutoReload(t *testing.T) {
+	testAutoReload(t)
+}
+
+func TestAutoReloadPolling(t *testing.T) {
+	testAutoReload(t, ForcePolling())
+}
+
+func TestAutoReloadFallback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	file := filepath.Join(dir, "cfg.yaml")
+
+	c := NewContext()
+	c.WithLogger(DiscardLogger)
+	c.WithConfigFile(file)
+	changed := make(chan string, 10)
+	c.Watch("name", func(old, new string) { changed <- new })
+	// notifications can't watch a missing directory, so the file is polled
+	stop, err := c.AutoReload(Debounce(10*time.Millisecond), PollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Logf("failed to start auto reload %v", err)
+		t.FailNow()
+	}
+	os.Mkdir(dir, 0o755)
+	os.WriteFile(file, []byte("name: polled\n"), 0o644)
+	select {
+	case v := <-changed:
+		if v != "polled" {
+			t.Logf("got:[%s] expected: [polled]", v)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Logf("no reload after the file was created")
+		t.FailNow()
+	}
+	stop()
+}
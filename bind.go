@@ -0,0 +1,332 @@
+package xvals
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BindError is returned by Bind and contains every key that was missing or
+// could not be parsed.
+type BindError struct {
+	Errors []error
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("failed to bind %d key(s):\n\t%s", len(e.Errors), strings.Join(msgs, "\n\t"))
+}
+
+// Unwrap returns the errors, for errors.Is and errors.As from Go 1.20.
+func (e *BindError) Unwrap() []error { return e.Errors }
+
+// Is reports whether any of the errors matches target, see ReloadError.Is.
+func (e *BindError) Is(target error) bool { return anyIs(e.Errors, target) }
+
+// As finds the first of the errors that matches target.
+func (e *BindError) As(target interface{}) bool { return anyAs(e.Errors, target) }
+
+// Bind fills the struct pointed to by v with values from the default context.
+// See Context.Bind for details.
+func Bind(v interface{}) error {
+	return defaultContext.Bind(v)
+}
+
+// Bind fills the struct pointed to by v with values from the context, or the
+// view. Fields are mapped to keys with the xvals tag:
+//
+//	type Config struct {
+//		Port    int           `xvals:"db_port,required,default=5432"`
+//		Timeout time.Duration `xvals:"timeout,default=5s"`
+//		DB      struct {
+//			Host string `xvals:"host"`      // key db_host
+//		} `xvals:"db"`
+//		API     *Endpoint     `xvals:"api"` // object EP_API_*
+//	}
+//
+// A field without tag uses the lowercased field name as key, a field tagged
+// "-" is ignored. Nested structs prefix the keys of their fields with their
+// own key, embedded structs without a tag share the prefix of the enclosing
+// struct. Slices are read as comma separated values and maps as comma
+// separated key=value pairs, with commas in values escaped as \,. Types
+// with a parser registered with RegisterParser are parsed with it.
+// Fields whose type is constructed by a registered Descriptor, like *Endpoint,
+// are resolved through the object store using the key as object name.
+//
+// The default option must come last, everything after default= is the
+// default value, so that lists and maps can have defaults, like
+// `xvals:"hosts,default=a,b"`.
+//
+// Fields with no value and no default are left untouched. Every missing
+// required key and every value that can't be parsed is reported in the
+// returned *BindError.
+func (a *accessors) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind requires a non nil pointer to a struct, got %T", v)
+	}
+	b := &binder{ctx: a.ctx}
+	b.bindStruct(rv.Elem(), a.prefix)
+	if len(b.errs) > 0 {
+		return &BindError{Errors: b.errs}
+	}
+	return nil
+}
+
+// binder keeps the state of one Bind call
+type binder struct {
+	ctx  *Context
+	errs []error
+}
+
+// bindTag is the parsed content of an xvals struct tag
+type bindTag struct {
+	name       string
+	def        string
+	hasDefault bool
+	required   bool
+}
+
+func parseBindTag(f reflect.StructField) (tag bindTag, skip bool) {
+	s, ok := f.Tag.Lookup("xvals")
+	if s == "-" {
+		return tag, true
+	}
+	parts := strings.Split(s, ",")
+	tag.name = strings.ToLower(strings.TrimSpace(parts[0]))
+	if !ok || tag.name == "" {
+		tag.name = strings.ToLower(f.Name)
+	}
+	for i, o := range parts[1:] {
+		switch o = strings.TrimSpace(o); {
+		case o == "required":
+			tag.required = true
+		case strings.HasPrefix(o, "default="):
+			// the default is the rest of the tag, commas included
+			tag.def = strings.TrimPrefix(strings.TrimLeft(strings.Join(parts[i+1:], ","), " "), "default=")
+			tag.hasDefault = true
+			return tag, false
+		}
+	}
+	return tag, false
+}
+
+func (b *binder) bindStruct(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			// unexported
+			continue
+		}
+		tag, skip := parseBindTag(f)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+		key := prefix + tag.name
+		if typ, ok := b.ctx.objects.objectType(f.Type); ok {
+			if fv.CanSet() {
+				b.bindObject(fv, typ, key, tag)
+			}
+			continue
+		}
+		if _, tagged := f.Tag.Lookup("xvals"); f.Anonymous && !tagged && isNestedStruct(f.Type) {
+			// Embedded structs share the prefix of the enclosing struct
+			if fv = settable(fv); fv.IsValid() {
+				b.bindStruct(fv, prefix)
+			}
+			continue
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		if isNestedStruct(f.Type) {
+			b.bindStruct(settable(fv), key+"_")
+			continue
+		}
+		b.bindValue(fv, key, tag)
+	}
+}
+
+// settable returns the struct value of v, allocating it if v is a nil pointer.
+func settable(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !v.CanSet() {
+				return reflect.Value{}
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Elem()
+	}
+	return v
+}
+
+// isNestedStruct returns true for structs, or pointers to structs, that
+// should be bound field by field.
+func isNestedStruct(t reflect.Type) bool {
+	if _, ok := registeredParser(t); ok {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := registeredParser(t); ok {
+		return false
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) || t == urlType || t == ipNetType {
+		return false
+	}
+	return !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func (b *binder) bindObject(v reflect.Value, typ, name string, tag bindTag) {
+	obj, err := b.ctx.GetObject(typ, name)
+	if err != nil {
+		if tag.required {
+			b.errs = append(b.errs, fmt.Errorf("missing required object %s %s", typ, name))
+		}
+		return
+	}
+	v.Set(reflect.ValueOf(obj))
+}
+
+func (b *binder) bindValue(v reflect.Value, key string, tag bindTag) {
+	s, o, err := b.ctx.lookup(key)
+	from := o.String()
+	if err != nil {
+		switch {
+		case tag.hasDefault:
+			s, from = tag.def, "default of field tag"
+		case tag.required:
+			b.errs = append(b.errs, fmt.Errorf("missing required key %s", key))
+			return
+		default:
+			return
+		}
+	}
+	if err := setValue(v, s); err != nil {
+		b.errs = append(b.errs, &ParseError{Key: key, Provider: from, Err: err})
+	}
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	ipNetType           = reflect.TypeOf(net.IPNet{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setValue parses s according to the type of v and stores the result in v.
+func setValue(v reflect.Value, s string) error {
+	if fn, ok := registeredParser(v.Type()); ok {
+		rv, err := parseRegistered(fn, v.Type(), s)
+		if err != nil {
+			return err
+		}
+		v.Set(rv)
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		n := reflect.New(v.Type().Elem())
+		if err := setValue(n.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(n)
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if v.Type() == urlType {
+		u, err := parseURL(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	if v.Type() == ipNetType {
+		n, err := parseCIDR(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*n))
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := parseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitList(s)
+		sl := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(sl.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(sl)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitList(s) {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("%q is not a key=value pair", item)
+			}
+			mk := reflect.New(v.Type().Key()).Elem()
+			if err := setValue(mk, strings.TrimSpace(kv[0])); err != nil {
+				return err
+			}
+			mv := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(mv, strings.TrimSpace(kv[1])); err != nil {
+				return err
+			}
+			m.SetMapIndex(mk, mv)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package xvals
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type bindDB struct {
+	Host string `xvals:"host,required"`
+	Port int    `xvals:"port,default=5432"`
+}
+
+type bindCommon struct {
+	Name string
+}
+
+type bindConfig struct {
+	bindCommon
+	DB        bindDB            `xvals:"db"`
+	Timeout   time.Duration     `xvals:"timeout,default=5s"`
+	Debug     *bool             `xvals:"debug"`
+	Tags      []string          `xvals:"tags"`
+	Ports     []int             `xvals:"ports"`
+	Labels    map[string]string `xvals:"labels"`
+	Ignored   string            `xvals:"-"`
+	API       *Endpoint         `xvals:"api,required"`
+	Untouched string            `xvals:"untouched"`
+	Hosts     []string          `xvals:"hosts,default=a,b"`
+	Limits    map[string]string `xvals:"limits,required,default=cpu=1, mem=2"`
+}
+
+func TestBind(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{
+		"name":           "svc",
+		"db_host":        "localhost",
+		"debug":          "true",
+		"tags":           "a, b,c",
+		"ports":          "1,2",
+		"labels":         "k=v,k2=v2",
+		"ignored":        "nope",
+		"ep_api_address": "localhost:1",
+	})
+	c.ReloadObjects()
+
+	cfg := bindConfig{Untouched: "keep"}
+	if e := c.Bind(&cfg); e != nil {
+		t.Logf("bind failed %v", e)
+		t.FailNow()
+	}
+	if cfg.Name != "svc" || cfg.DB.Host != "localhost" || cfg.DB.Port != 5432 {
+		t.Logf("unexpected values %+v", cfg)
+		t.FailNow()
+	}
+	if cfg.Timeout != 5*time.Second || cfg.Debug == nil || !*cfg.Debug {
+		t.Logf("unexpected values %+v", cfg)
+		t.FailNow()
+	}
+	if len(cfg.Tags) != 3 || cfg.Tags[1] != "b" || len(cfg.Ports) != 2 || cfg.Ports[1] != 2 {
+		t.Logf("unexpected lists %v %v", cfg.Tags, cfg.Ports)
+		t.FailNow()
+	}
+	if cfg.Labels["k2"] != "v2" || cfg.Ignored != "" || cfg.Untouched != "keep" {
+		t.Logf("unexpected values %+v", cfg)
+		t.FailNow()
+	}
+	if len(cfg.Hosts) != 2 || cfg.Hosts[1] != "b" || cfg.Limits["mem"] != "2" {
+		t.Logf("unexpected defaults %v %v", cfg.Hosts, cfg.Limits)
+		t.FailNow()
+	}
+	if cfg.API == nil || cfg.API.Address != "localhost:1" {
+		t.Logf("unexpected endpoint %v", cfg.API)
+		t.FailNow()
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{"db_port": "many", "timeout": "soon"})
+
+	var cfg bindConfig
+	e := c.Bind(&cfg)
+	var be *BindError
+	if !errors.As(e, &be) {
+		t.Logf("expected BindError, got %v", e)
+		t.FailNow()
+	}
+	// db_host, db_port, timeout and api
+	if len(be.Errors) != 4 {
+		t.Logf("expected 4 errors, got %v", be)
+		t.FailNow()
+	}
+	if c.Bind(cfg) == nil {
+		t.Logf("expected error when not binding to a pointer")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,235 @@
+package xvals
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Priority of a provider in a context. A provider with a higher priority
+// overrides the values of providers with lower priorities. Providers with
+// the same priority are consulted in the order they were added.
+type Priority int
+
+// The priorities used by the With methods of a Context, from lowest to
+// highest. Any other value can be used with Add.
+const (
+	PriorityDefaults Priority = 100 // WithMap
+	PriorityFile     Priority = 200 // WithConfigFile, WithDotenv, WithDirectory
+	PriorityProfile  Priority = 300 // WithProfile
+	PriorityEnv      Priority = 400 // WithEnvironment
+	PriorityArgs     Priority = 500 // WithArgs, WithFlagSet
+)
+
+// ProviderInfo describes a provider in the chain of a context
+type ProviderInfo struct {
+	Name     string
+	Priority Priority
+	Kind     string // see DescribedProvider
+	Source   string // see DescribedProvider
+	Provider XvalProvider
+}
+
+// entry is a provider in the chain of a context
+type entry struct {
+	name     string
+	priority Priority
+	p        XvalProvider
+}
+
+// providerChain is a snapshot of the providers of a context, never modified
+// once stored.
+type providerChain struct {
+	entries   []entry        // ordered by priority, highest first
+	providers []XvalProvider // the providers of entries, in the same order
+}
+
+// entries returns the current snapshot of the provider entries.
+func (c *Context) entries() []entry {
+	if pc, ok := c.providers.Load().(*providerChain); ok {
+		return pc.entries
+	}
+	return nil
+}
+
+// update publishes the entries returned by fn, which is given a copy of the
+// current entries that it may modify.
+func (c *Context) update(fn func([]entry) ([]entry, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.entries()
+	next, err := fn(append(make([]entry, 0, len(old)+1), old...))
+	if err != nil {
+		return err
+	}
+	pc := &providerChain{entries: next, providers: make([]XvalProvider, len(next))}
+	for i, e := range next {
+		pc.providers[i] = e.p
+	}
+	c.providers.Store(pc)
+	return nil
+}
+
+// find returns the index of the entry named name, or -1.
+func find(entries []entry, name string) int {
+	for i, e := range entries {
+		if e.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert puts e at position i of entries
+func insert(entries []entry, i int, e entry) []entry {
+	entries = append(entries, entry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = e
+	return entries
+}
+
+// insertByPriority puts e after the entries with the same or higher priority
+func insertByPriority(entries []entry, e entry) []entry {
+	i := 0
+	for i < len(entries) && entries[i].priority >= e.priority {
+		i++
+	}
+	return insert(entries, i, e)
+}
+
+// Add adds a provider to the context with the given name and priority. It
+// is consulted after the providers with the same or higher priority that
+// were added before it. The name must be unique in the context.
+func (c *Context) Add(name string, priority Priority, p XvalProvider) error {
+	return c.update(func(entries []entry) ([]entry, error) {
+		if err := checkName(entries, name); err != nil {
+			return nil, err
+		}
+		c.attach(name, p)
+		return insertByPriority(entries, entry{name, priority, p}), nil
+	})
+}
+
+// InsertBefore adds a provider named name right before the provider named
+// ref, so that it overrides ref. It gets the priority of ref.
+func (c *Context) InsertBefore(ref, name string, p XvalProvider) error {
+	return c.insertAt(ref, name, p, 0)
+}
+
+// InsertAfter adds a provider named name right after the provider named
+// ref, so that ref overrides it. It gets the priority of ref.
+func (c *Context) InsertAfter(ref, name string, p XvalProvider) error {
+	return c.insertAt(ref, name, p, 1)
+}
+
+func (c *Context) insertAt(ref, name string, p XvalProvider, offset int) error {
+	return c.update(func(entries []entry) ([]entry, error) {
+		if err := checkName(entries, name); err != nil {
+			return nil, err
+		}
+		i := find(entries, ref)
+		if i < 0 {
+			return nil, fmt.Errorf("provider %s %w", ref, ErrNotFound)
+		}
+		c.attach(name, p)
+		return insert(entries, i+offset, entry{name, entries[i].priority, p}), nil
+	})
+}
+
+// Remove removes the provider named name from the context. The provider is
+// not closed.
+func (c *Context) Remove(name string) error {
+	return c.update(func(entries []entry) ([]entry, error) {
+		i := find(entries, name)
+		if i < 0 {
+			return nil, fmt.Errorf("provider %s %w", name, ErrNotFound)
+		}
+		return append(entries[:i], entries[i+1:]...), nil
+	})
+}
+
+// Replace replaces the provider named name with p, keeping its name and
+// position. The replaced provider is not closed.
+func (c *Context) Replace(name string, p XvalProvider) error {
+	return c.update(func(entries []entry) ([]entry, error) {
+		i := find(entries, name)
+		if i < 0 {
+			return nil, fmt.Errorf("provider %s %w", name, ErrNotFound)
+		}
+		c.attach(name, p)
+		entries[i].p = p
+		return entries, nil
+	})
+}
+
+// Providers returns the providers of the context, in the order they are
+// consulted.
+func (c *Context) Providers() []ProviderInfo {
+	entries := c.entries()
+	res := make([]ProviderInfo, len(entries))
+	for i, e := range entries {
+		kind, source := describe(e.p)
+		res[i] = ProviderInfo{Name: e.name, Priority: e.priority, Kind: kind, Source: source, Provider: e.p}
+	}
+	return res
+}
+
+// checkName returns an error if name can't be used for a new provider
+func checkName(entries []entry, name string) error {
+	if name == "" {
+		return fmt.Errorf("a provider must have a name")
+	}
+	if find(entries, name) >= 0 {
+		return fmt.Errorf("a provider named %s already exists", name)
+	}
+	return nil
+}
+
+// with adds p with the given priority, named after its kind and source. A
+// number is appended to the name if it is already taken.
+func (c *Context) with(priority Priority, p XvalProvider) XvalProvider {
+	kind, source := describe(p)
+	base := kind
+	if source != "" {
+		base += ":" + source
+	}
+	for {
+		name := base
+		for n := 2; find(c.entries(), name) >= 0; n++ {
+			name = base + "-" + strconv.Itoa(n)
+		}
+		// fails only if another goroutine took the name meanwhile
+		if c.Add(name, priority, p) == nil {
+			return p
+		}
+	}
+}
+
+// Add adds a provider to the default context. See Context.Add.
+func Add(name string, priority Priority, p XvalProvider) error {
+	return defaultContext.Add(name, priority, p)
+}
+
+// InsertBefore adds a provider to the default context right before ref.
+func InsertBefore(ref, name string, p XvalProvider) error {
+	return defaultContext.InsertBefore(ref, name, p)
+}
+
+// InsertAfter adds a provider to the default context right after ref.
+func InsertAfter(ref, name string, p XvalProvider) error {
+	return defaultContext.InsertAfter(ref, name, p)
+}
+
+// Remove removes a provider from the default context.
+func Remove(name string) error {
+	return defaultContext.Remove(name)
+}
+
+// Replace replaces a provider of the default context.
+func Replace(name string, p XvalProvider) error {
+	return defaultContext.Replace(name, p)
+}
+
+// Providers returns the providers of the default context.
+func Providers() []ProviderInfo {
+	return defaultContext.Providers()
+}
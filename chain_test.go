@@ -0,0 +1,94 @@
+package xvals
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// removeProvider removes p from the default context, so that tests don't
+// leak providers into each other.
+func removeProvider(t *testing.T, p XvalProvider) {
+	for _, info := range Providers() {
+		if info.Provider == p {
+			if err := Remove(info.Name); err != nil {
+				t.Logf("failed to remove %s: %v", info.Name, err)
+				t.FailNow()
+			}
+			return
+		}
+	}
+	t.Logf("provider %v not found", p)
+	t.FailNow()
+}
+
+func names(c *Context) []string {
+	var res []string
+	for _, info := range c.Providers() {
+		res = append(res, info.Name)
+	}
+	return res
+}
+
+func TestProviderChain(t *testing.T) {
+	c := NewContext()
+	c.WithArgs([]string{"--key=args"})
+	c.WithMap(map[string]string{"key": "map"})
+	c.WithMap(map[string]string{"key": "map2", "other": "map2"})
+	c.Add("defaults", PriorityDefaults-1, newMapProvider(map[string]string{"key": "defaults", "last": "defaults"}))
+	c.Add("file", PriorityFile, newMapProvider(map[string]string{"key": "file"}))
+
+	exp := "[args file map map-2 defaults]"
+	if got := fmt.Sprint(names(c)); got != exp {
+		t.Logf("expected providers %v, got %v", exp, got)
+		t.FailNow()
+	}
+	GetGoodC(t, c, "key", "args")
+
+	if err := c.Remove("args"); err != nil {
+		t.Logf("unexpected error %v", err)
+		t.FailNow()
+	}
+	GetGoodC(t, c, "key", "file")
+
+	c.InsertBefore("file", "override", newMapProvider(map[string]string{"key": "override"}))
+	GetGoodC(t, c, "key", "override")
+	c.Replace("override", newMapProvider(map[string]string{"key": "replaced"}))
+	GetGoodC(t, c, "key", "replaced")
+	c.InsertAfter("map-2", "fallback", newMapProvider(map[string]string{"last": "fallback"}))
+	GetGoodC(t, c, "last", "fallback")
+
+	if err := c.Add("file", PriorityEnv, newMapProvider(nil)); err == nil {
+		t.Logf("expected an error for a duplicate name")
+		t.FailNow()
+	}
+	if err := c.Remove("missing"); !errors.Is(err, ErrNotFound) {
+		t.Logf("expected ErrNotFound, got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestFailedAddLeavesProvider(t *testing.T) {
+	c1 := NewContext()
+	p := c1.WithMap(map[string]string{"DB-HOST": "h"})
+	name := p.(interface{ chainName() string }).chainName()
+
+	c2 := NewContext()
+	c2.WithKeyNormalizer(StrictKeys)
+	c2.Add("taken", PriorityDefaults, newMapProvider(nil))
+	for _, err := range []error{
+		c2.Add("taken", PriorityDefaults, p),
+		c2.InsertBefore("missing", "other", p),
+		c2.Replace("missing", p),
+	} {
+		if err == nil {
+			t.Logf("expected the provider not to be added")
+			t.FailNow()
+		}
+	}
+	if got := p.(interface{ chainName() string }).chainName(); got != name {
+		t.Logf("expected the provider to keep its name %s, got %s", name, got)
+		t.FailNow()
+	}
+	GetGoodC(t, c1, "db_host", "h")
+}
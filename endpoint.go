@@ -81,20 +81,35 @@ type Endpoint struct {
 	ClientCert   string `yaml:"client_cert,omitempty"`
 	ClientKey    string `yaml:"client_key,omitempty"`
 	Path         string `yaml:"path,omitempty"`
+
+	name   string        // name in the object store
+	logger func() Logger // logger of the store, resolved on each use
 }
 
-func (e *Endpoint) Write(name string, w io.Writer) {
-	un := strings.ToUpper(name)
+// setLogger sets the name of the endpoint and how it gets its logger
+func (e *Endpoint) setLogger(name string, l func() Logger) {
+	e.name, e.logger = name, l
+}
 
-	fmt.Fprintf(w, "EP_%s_ADDRESS=%s", un, e.Address)
-	fmt.Fprintf(w, "EP_%s_TLS=%s", un, e.TLS)
-	fmt.Fprintf(w, "EP_%s_SERVER_CACERT=%s", un, e.ServerCACert)
-	fmt.Fprintf(w, "EP_%s_SERVER_CERT=%s", un, e.ServerCert)
-	fmt.Fprintf(w, "EP_%s_SERVER_KEY=%s", un, e.ServerKey)
-	fmt.Fprintf(w, "EP_%s_CLIENT_CACERT=%s", un, e.ClientCACert)
-	fmt.Fprintf(w, "EP_%s_CLIENT_CERT=%s", un, e.ClientCert)
-	fmt.Fprintf(w, "EP_%s_CLIENT_KEY=%s", un, e.ClientKey)
-	fmt.Fprintf(w, "EP_%s_PATH=%s", un, e.Path)
+// log returns the logger of the endpoint
+func (e *Endpoint) log() Logger {
+	if e.logger == nil {
+		return defaultLogger
+	}
+	return e.logger()
+}
+
+// Write the endpoint to w in .env format, as the keys that make up an
+// endpoint with the given name.
+func (e *Endpoint) Write(name string, w io.Writer) error {
+	un := strings.ToUpper(name)
+	fields := e.Fields()
+	for _, f := range EndpointDescr.Fields() {
+		if err := writeDotenvLine(w, fmt.Sprintf("%s_%s_%s", TpEndpoint, un, f), fields[f]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // UseTLS returns false if TLS should not be considered
@@ -183,6 +198,9 @@ func (d *epDescriptor) Type() string { return TpEndpoint }
 func (d *epDescriptor) Fields() []string {
 	return []string{"ADDRESS", "TLS", "SERVER_CACERT", "SERVER_CERT", "SERVER_KEY", "CLIENT_CACERT", "CLIENT_CERT", "CLIENT_KEY", "PATH"}
 }
+func (d *epDescriptor) SecretFields() []string {
+	return []string{"SERVER_KEY", "CLIENT_KEY"}
+}
 func (d *epDescriptor) Construct() Object {
 	return &Endpoint{}
 }
@@ -5,7 +5,6 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -17,7 +16,12 @@ import (
 
 // GetEndpoint retrieves and endpoint from the external context
 func GetEndpoint(name string) (*Endpoint, error) {
-	obj, err := GetObject(TpEndpoint, name)
+	return defaultContext.GetEndpoint(name)
+}
+
+// GetEndpoint retrieves an endpoint from the object store of the context
+func (c *Context) GetEndpoint(name string) (*Endpoint, error) {
+	obj, err := c.GetObject(TpEndpoint, name)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +54,7 @@ func (e *Endpoint) GetClientTLSConfig() (*tls.Config, error) {
 		if err != nil {
 			// if we couldn't load the system certs create an empty certpool and
 			// continue
-			log.Printf("failed to load system certificates, will continues with an empty cert pool")
+			e.log().Warn("failed to load system certificates, continuing with an empty cert pool", "endpoint", e.name, "error", err)
 			tlsConfig.RootCAs = x509.NewCertPool()
 		}
 		if string(serverCaCert) != "external" {
@@ -0,0 +1,96 @@
+package xvals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned, wrapped, when a key or an object doesn't exist.
+// Test for it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// ParseError is returned when a value can't be parsed as the requested type.
+type ParseError struct {
+	Key      string
+	Provider string // provider the value came from, see Origin.String
+	Err      error  // error of the parser, like a *strconv.NumError
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse key %s from %s: %v", e.Key, e.Provider, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ProviderError is returned when a provider fails to load its values.
+type ProviderError struct {
+	Kind   string // kind of provider, see DescribedProvider
+	Source string // source of the provider, like a file path
+	Err    error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%s provider: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s provider %s: %v", e.Kind, e.Source, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// anyIs reports whether any of errs matches target, with errors.Is
+func anyIs(errs []error, target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyAs finds the first of errs that matches target, with errors.As
+func anyAs(errs []error, target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// providerError wraps err in a *ProviderError describing p
+func providerError(p XvalProvider, err error) error {
+	kind, source := describe(p)
+	return &ProviderError{Kind: kind, Source: source, Err: err}
+}
+
+// parseError wraps err in a *ParseError for key, with the value from o
+func parseError(key string, o Origin, err error) error {
+	return &ParseError{Key: key, Provider: o.String(), Err: err}
+}
+
+// A LifecycleProvider is a provider whose values are loaded and released
+// explicitly. All providers of this package implement it.
+type LifecycleProvider interface {
+	Refresher
+
+	// Load loads the values from the source, like Refresh, unless ctx is
+	// done. Errors are returned as *ProviderError.
+	Load(ctx context.Context) error
+
+	// Close releases the resources held by the provider. The provider must
+	// not be used after it has been closed.
+	Close() error
+}
+
+// load refreshes r unless ctx is done
+func load(ctx context.Context, r Refresher) error {
+	if err := ctx.Err(); err != nil {
+		return providerError(r, err)
+	}
+	if err := r.Refresh(); err != nil {
+		return providerError(r, err)
+	}
+	return nil
+}
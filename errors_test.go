@@ -0,0 +1,108 @@
+package xvals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	c := NewContext()
+	c.WithMap(map[string]string{"port": "eighty", "debug": "maybe"})
+
+	if _, err := c.Value("missing"); !errors.Is(err, ErrNotFound) {
+		t.Logf("expected ErrNotFound, got %v", err)
+		t.FailNow()
+	}
+	if _, err := c.GetObject(TpEndpoint, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Logf("expected ErrNotFound for object, got %v", err)
+		t.FailNow()
+	}
+
+	_, err := c.IntValue("port")
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Key != "port" || pe.Provider != "map (priority 100)" {
+		t.Logf("expected a ParseError for port from map (priority 100), got %v", err)
+		t.FailNow()
+	}
+	var ne *strconv.NumError
+	if !errors.As(err, &ne) {
+		t.Logf("expected the strconv error to be wrapped, got %v", err)
+		t.FailNow()
+	}
+	if _, err := c.BoolValue("debug"); !errors.As(err, &pe) || pe.Key != "debug" {
+		t.Logf("expected a ParseError for debug, got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(cfg, []byte("a: 1\n"), 0644)
+	c := NewContext()
+	c.WithConfigFile(cfg)
+
+	os.Remove(cfg)
+	err := c.Load(context.Background())
+	var pe *ProviderError
+	if !errors.As(err, &pe) || pe.Kind != "file" || !errors.Is(err, os.ErrNotExist) {
+		t.Logf("expected a ProviderError for the missing file, got %v", err)
+		t.FailNow()
+	}
+	GetGoodC(t, c, "a", "1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Load(ctx); !errors.Is(err, context.Canceled) {
+		t.Logf("expected the load to be canceled, got %v", err)
+		t.FailNow()
+	}
+	if err := c.Close(); err != nil {
+		t.Logf("unexpected error on close %v", err)
+		t.FailNow()
+	}
+}
+
+func TestLoadCanceled(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{"ep_api_address": "localhost:1"})
+	var events []ObjectEvent
+	c.WatchObjects(func(ev ObjectEvent) { events = append(events, ev) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := c.Load(ctx)
+	if re, ok := err.(*ReloadError); !ok || len(re.Errors) != 1 || !errors.Is(err, context.Canceled) {
+		t.Logf("expected a ReloadError with the cancellation only, got %v", err)
+		t.FailNow()
+	}
+	if len(events) != 0 {
+		t.Logf("expected the objects not to be reloaded, got %v", events)
+		t.FailNow()
+	}
+}
+
+func TestMultiErrors(t *testing.T) {
+	errs := []error{fmt.Errorf("key x %w", ErrNotFound), &ParseError{Key: "port", Err: strconv.ErrSyntax}}
+	for _, err := range []interface {
+		error
+		Is(error) bool
+		As(interface{}) bool
+	}{&ReloadError{Errors: errs}, &BindError{Errors: errs}} {
+		// the methods are what errors.Is and errors.As use before Go 1.20
+		var pe *ParseError
+		if !err.Is(ErrNotFound) || !err.Is(strconv.ErrSyntax) || !err.As(&pe) || pe.Key != "port" {
+			t.Logf("expected the errors of %T to be found", err)
+			t.FailNow()
+		}
+		if err.Is(os.ErrNotExist) {
+			t.Logf("unexpected match for %T", err)
+			t.FailNow()
+		}
+	}
+}
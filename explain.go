@@ -0,0 +1,122 @@
+package xvals
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Origin describes a provider that defines a value for a key.
+type Origin struct {
+	Name     string   // name of the provider in the chain, see Providers
+	Kind     string   // kind of provider, see DescribedProvider
+	Source   string   // source of the provider, like a file path
+	Priority Priority // priority of the provider
+	Position int      // position in the provider chain, 0 is consulted first
+	Value    string   // the value the provider has for the key
+}
+
+// String returns the name and priority of the provider, like
+// "map (priority 100)", followed by the source if the name doesn't tell it.
+func (o Origin) String() string {
+	s := fmt.Sprintf("%s (priority %d)", o.Name, o.Priority)
+	if o.Source != "" && !strings.Contains(o.Name, o.Source) {
+		s += " " + o.Source
+	}
+	return s
+}
+
+// An Explanation tells where the value of a key comes from.
+type Explanation struct {
+	Key string
+
+	// Winner is the provider whose value is returned by Value, nil if no
+	// provider defines the key.
+	Winner *Origin
+
+	// Shadowed are the providers that also define the key, but are hidden by
+	// the winner. Ordered by priority.
+	Shadowed []Origin
+}
+
+func (e *Explanation) String() string {
+	if e.Winner == nil {
+		return fmt.Sprintf("%s: not defined", e.Key)
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s=%q from %s", e.Key, e.Winner.Value, e.Winner)
+	for _, o := range e.Shadowed {
+		fmt.Fprintf(b, "\n\tshadows %q from %s", o.Value, o)
+	}
+	return b.String()
+}
+
+// origin returns the origin of a value from the provider e at position i in
+// the chain.
+func origin(e entry, i int, val string) Origin {
+	kind, source := describe(e.p)
+	return Origin{Name: e.name, Kind: kind, Source: source, Priority: e.priority, Position: i, Value: val}
+}
+
+// Explain returns every provider of the context that defines key, and which
+// of them decides the value.
+func (c *Context) Explain(key string) *Explanation {
+	lcVal := c.normalize(key)
+	e := &Explanation{Key: lcVal}
+	for i, p := range c.entries() {
+		v, err := valueOf(p.p, lcVal)
+		if err != nil {
+			continue
+		}
+		o := origin(p, i, v)
+		if e.Winner == nil {
+			e.Winner = &o
+			continue
+		}
+		e.Shadowed = append(e.Shadowed, o)
+	}
+	if spec, ok := c.schemaSpec(lcVal); ok && spec.Default != "" {
+		o := c.schemaOrigin(spec.Default)
+		if e.Winner == nil {
+			e.Winner = &o
+		} else {
+			e.Shadowed = append(e.Shadowed, o)
+		}
+	}
+	return e
+}
+
+// schemaOrigin returns the origin of a default value from the schema, which
+// is consulted after all providers.
+func (c *Context) schemaOrigin(val string) Origin {
+	return Origin{Name: "schema", Kind: "schema", Position: len(c.entries()), Value: val}
+}
+
+// DumpOrigins returns the same merged set of values as Dump, together with
+// the origin of each value.
+func (c *Context) DumpOrigins() map[string]Origin {
+	res := make(map[string]Origin)
+	for k, v := range c.schemaDefaults() {
+		res[k] = c.schemaOrigin(v)
+	}
+	providers := c.entries()
+	// loop backwards, so that the values of the more prioritized
+	// providers are used.
+	for i := len(providers) - 1; i >= 0; i-- {
+		for k, v := range dumpOf(providers[i].p) {
+			res[k] = origin(providers[i], i, v)
+		}
+	}
+	return res
+}
+
+// Explain returns every provider of the default context that defines key,
+// and which of them decides the value.
+func Explain(key string) *Explanation {
+	return defaultContext.Explain(key)
+}
+
+// DumpOrigins returns the merged set of values of the default context
+// together with the origin of each value.
+func DumpOrigins() map[string]Origin {
+	return defaultContext.DumpOrigins()
+}
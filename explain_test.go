@@ -0,0 +1,44 @@
+package xvals
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	c := NewContext()
+	c.WithProfile("testdata/testprofiles.yaml")
+	c.WithConfigFile("testdata/testctx1.yaml")
+	c.WithMap(map[string]string{"key1": "map1", "ep_staffan_address": "map:1"})
+
+	e := c.Explain("KEY1")
+	if e.Winner == nil || e.Winner.Kind != "profile" || e.Winner.Value != "val1" {
+		t.Logf("unexpected winner %v", e)
+		t.FailNow()
+	}
+	if e.Winner.Source != "testdata/testprofiles.yaml#profile1" {
+		t.Logf("unexpected source %s", e.Winner.Source)
+		t.FailNow()
+	}
+	if len(e.Shadowed) != 1 || e.Shadowed[0].Value != "map1" || e.Shadowed[0].Position != 2 || e.Shadowed[0].Priority != PriorityDefaults {
+		t.Logf("unexpected shadowed %v", e.Shadowed)
+		t.FailNow()
+	}
+
+	if s := e.Shadowed[0].String(); s != "map (priority 100)" {
+		t.Logf("unexpected origin %s", s)
+		t.FailNow()
+	}
+
+	if e := c.Explain("missing"); e.Winner != nil {
+		t.Logf("expected no winner, got %v", e)
+		t.FailNow()
+	}
+
+	o := c.DumpOrigins()["ep_staffan_address"]
+	abs, _ := filepath.Abs("testdata/testctx1.yaml")
+	if o.Kind != "file" || o.Source != abs || o.Value != "localhost:12345" {
+		t.Logf("unexpected origin %v", o)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,316 @@
+package xvals
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportFormat is a format values can be exported in
+type ExportFormat int
+
+// The supported export formats. Keys are exported in sorted order.
+const (
+	ExportYAML      ExportFormat = iota // a YAML map, see Nested
+	ExportJSON                          // a JSON object, see Nested
+	ExportDotenv                        // KEY=value lines, see WriteDotenv
+	ExportShell                         // export KEY='value' lines
+	ExportConfigMap                     // a Kubernetes ConfigMap
+	ExportSecret                        // a Kubernetes Secret
+)
+
+func (f ExportFormat) String() string {
+	switch f {
+	case ExportYAML:
+		return "yaml"
+	case ExportJSON:
+		return "json"
+	case ExportDotenv:
+		return "dotenv"
+	case ExportShell:
+		return "shell"
+	case ExportConfigMap:
+		return "configmap"
+	case ExportSecret:
+		return "secret"
+	default:
+		return fmt.Sprintf("ExportFormat(%d)", int(f))
+	}
+}
+
+// exporter writes values in an ExportFormat
+type exporter struct {
+	redact    bool
+	sep       string // nest YAML and JSON keys at sep, empty for flat
+	name      string
+	namespace string
+	isSecret  func(key string) bool
+}
+
+// ExportOption configures an export
+type ExportOption func(*exporter)
+
+// Redact replaces the values of secret keys with Redacted, see IsSecret.
+// Empty values are kept.
+func Redact() ExportOption {
+	return func(e *exporter) {
+		e.redact = true
+	}
+}
+
+// Nested splits the keys at sep into nested maps when exporting YAML or
+// JSON, which makes
+//
+//	ep_api_address=localhost:1
+//
+// with the separator "_"
+//
+//	ep:
+//	  api:
+//	    address: localhost:1
+//
+// A key that is also the start of other keys, like db for db_host, can't
+// hold both a value and a map, the longer keys are then kept unsplit at the
+// top level. The output reads back to the same keys with WithConfigFile and
+// the same separator.
+func Nested(sep string) ExportOption {
+	return func(e *exporter) {
+		e.sep = sep
+	}
+}
+
+// ManifestName sets the name of exported Kubernetes manifests, the default
+// is "xvals".
+func ManifestName(name string) ExportOption {
+	return func(e *exporter) {
+		e.name = name
+	}
+}
+
+// Namespace sets the namespace of exported Kubernetes manifests.
+func Namespace(ns string) ExportOption {
+	return func(e *exporter) {
+		e.namespace = ns
+	}
+}
+
+// ExportValues writes kv to w in the format f. Without a context the secret
+// keys are those matching DefaultSecretPatterns.
+func ExportValues(w io.Writer, kv map[string]string, f ExportFormat, opts ...ExportOption) error {
+	return newExporter(matchesDefaultPatterns, opts).export(w, kv, f)
+}
+
+// Export writes the values of the context to w in the format f, with their
+// references expanded. Values that fail to expand are written as they are.
+// A literal ${ of an expanded value is written escaped as $${, so that it
+// isn't expanded again when the output is read back, whether from a file,
+// from the environment it was sourced into or from a mounted ConfigMap or
+// Secret.
+func (c *Context) Export(w io.Writer, f ExportFormat, opts ...ExportOption) error {
+	return newExporter(c.isSecret, opts).export(w, c.expandedDump(true), f)
+}
+
+// ExportObjects writes the keys and values of the objects of the context to
+// w in the format f, see ObjectStore.Dump.
+func (c *Context) ExportObjects(w io.Writer, f ExportFormat, opts ...ExportOption) error {
+	return newExporter(c.isSecret, opts).export(w, c.objects.Dump(), f)
+}
+
+// Export writes the keys and values of the objects of the store to w in the
+// format f. Secret keys are the secret fields of the descriptors and the
+// keys matching DefaultSecretPatterns.
+func (c *ObjectStore) Export(w io.Writer, f ExportFormat, opts ...ExportOption) error {
+	isSecret := func(key string) bool {
+		return c.secretField(key) || matchesDefaultPatterns(key)
+	}
+	return newExporter(isSecret, opts).export(w, c.Dump(), f)
+}
+
+// Dump returns the keys and values that make up the objects of the store,
+// lower cased like the keys of a context. Every field of the descriptor of an
+// object is included.
+func (c *ObjectStore) Dump() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make(map[string]string)
+	for k, obj := range c.objects {
+		typ, name := FromKey(k)
+		d, ok := c.descriptors[typ]
+		if !ok {
+			continue
+		}
+		for _, f := range d.Fields() {
+			v, err := obj.Get(f)
+			if err != nil {
+				continue
+			}
+			res[strings.ToLower(fmt.Sprintf("%s_%s_%s", typ, name, f))] = v
+		}
+	}
+	return res
+}
+
+// matchesDefaultPatterns tells whether key matches DefaultSecretPatterns
+func matchesDefaultPatterns(key string) bool {
+	for _, p := range DefaultSecretPatterns {
+		if ok, _ := path.Match(p, LooseKeys(key)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func newExporter(isSecret func(string) bool, opts []ExportOption) *exporter {
+	e := &exporter{name: "xvals", isSecret: isSecret}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+func (e *exporter) export(w io.Writer, kv map[string]string, f ExportFormat) error {
+	if e.redact {
+		redacted := make(map[string]string, len(kv))
+		for k, v := range kv {
+			if v != "" && e.isSecret(k) {
+				v = Redacted
+			}
+			redacted[k] = v
+		}
+		kv = redacted
+	}
+	switch f {
+	case ExportYAML:
+		return writeYAML(w, e.tree(kv))
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e.tree(kv))
+	case ExportDotenv:
+		return WriteDotenv(w, kv)
+	case ExportShell:
+		return writeShell(w, kv)
+	case ExportConfigMap, ExportSecret:
+		return writeYAML(w, e.manifest(kv, f))
+	default:
+		return fmt.Errorf("unknown export format %s", f)
+	}
+}
+
+// writeYAML writes v to w as a YAML document, indented by two spaces
+func writeYAML(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// tree returns kv as it is, or nested at the separator
+func (e *exporter) tree(kv map[string]string) interface{} {
+	if e.sep == "" {
+		return kv
+	}
+	res := make(map[string]interface{})
+	// sorted, so that a key is nested before the keys it is the start of
+	for _, k := range sortedKeys(kv) {
+		nest(res, strings.Split(k, e.sep), kv[k], e.sep)
+	}
+	return res
+}
+
+// nest stores val in root at the path parts, or at the joined parts if a
+// part on the path already holds a value.
+func nest(root map[string]interface{}, parts []string, val, sep string) {
+	m := root
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := m[p]
+		if !ok {
+			child = make(map[string]interface{})
+			m[p] = child
+		}
+		if m, ok = child.(map[string]interface{}); !ok {
+			root[strings.Join(parts, sep)] = val
+			return
+		}
+	}
+	m[parts[len(parts)-1]] = val
+}
+
+// manifest is a Kubernetes ConfigMap or Secret
+type manifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   manifestMetadata  `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type manifestMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// manifest returns kv as a ConfigMap or Secret, with the keys upper cased
+// like environment variables. The values of a Secret are base64 encoded.
+func (e *exporter) manifest(kv map[string]string, f ExportFormat) *manifest {
+	m := &manifest{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   manifestMetadata{Name: e.name, Namespace: e.namespace},
+		Data:       make(map[string]string, len(kv)),
+	}
+	if f == ExportSecret {
+		m.Kind, m.Type = "Secret", "Opaque"
+	}
+	for k, v := range kv {
+		if f == ExportSecret {
+			v = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		m.Data[strings.ToUpper(k)] = v
+	}
+	return m
+}
+
+// shellReplacer makes a key a valid shell variable name
+var shellReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// writeShell writes kv as export lines for a POSIX shell, ordered by key.
+// Values are single quoted, so they are not expanded by the shell.
+func writeShell(w io.Writer, kv map[string]string) error {
+	for _, k := range sortedKeys(kv) {
+		val := "'" + strings.ReplaceAll(kv[k], "'", `'\''`) + "'"
+		if _, err := fmt.Fprintf(w, "export %s=%s\n", shellReplacer.Replace(strings.ToUpper(k)), val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of kv in sorted order
+func sortedKeys(kv map[string]string) []string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Export writes the values of the default context to w in the format f.
+func Export(w io.Writer, f ExportFormat, opts ...ExportOption) error {
+	return defaultContext.Export(w, f, opts...)
+}
+
+// ExportObjects writes the objects of the default context to w in the
+// format f.
+func ExportObjects(w io.Writer, f ExportFormat, opts ...ExportOption) error {
+	return defaultContext.ExportObjects(w, f, opts...)
+}
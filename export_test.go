@@ -0,0 +1,144 @@
+package xvals
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func exportContext() *Context {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{
+		"db":                "1",
+		"db_host":           "h",
+		"name":              "it's",
+		"greeting":          "hi ${name}",
+		"literal":           "$${name} stays",
+		"ep_api_address":    "localhost:1",
+		"ep_api_server_key": "PEM",
+	})
+	c.ReloadObjects()
+	return c
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	c := exportContext()
+	want, _ := c.resolvedDump()
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		file string
+		f    ExportFormat
+		opts []ExportOption
+	}{
+		{"flat.yaml", ExportYAML, nil},
+		{"nested.yaml", ExportYAML, []ExportOption{Nested("_")}},
+		{"nested.json", ExportJSON, []ExportOption{Nested("_")}},
+		{"values.env", ExportDotenv, nil},
+	} {
+		b := &bytes.Buffer{}
+		if err := c.Export(b, tc.f, tc.opts...); err != nil {
+			t.Logf("%s: export failed %v", tc.file, err)
+			t.FailNow()
+		}
+		file := filepath.Join(dir, tc.file)
+		os.WriteFile(file, b.Bytes(), 0600)
+		r := NewContext()
+		if tc.f == ExportDotenv {
+			r.WithDotenv(file)
+		} else {
+			r.WithConfigFile(file)
+		}
+		if got, _ := r.resolvedDump(); !reflect.DeepEqual(got, want) {
+			t.Logf("%s: expected %v, got %v from\n%s", tc.file, want, got, b)
+			t.FailNow()
+		}
+	}
+
+	// shell exports are read back from the environment they are sourced into
+	b := &bytes.Buffer{}
+	c.Export(b, ExportShell)
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		kv := strings.SplitN(strings.TrimPrefix(line, "export "), "=", 2)
+		val := strings.ReplaceAll(strings.Trim(kv[1], "'"), `'\''`, "'")
+		t.Setenv("XVALS_EXPORT_"+kv[0], val)
+	}
+	r := NewContext()
+	r.WithEnvironment(Prefix("XVALS_EXPORT_"), StripPrefix())
+	if got, _ := r.resolvedDump(); !reflect.DeepEqual(got, want) {
+		t.Logf("shell: expected %v, got %v from\n%s", want, got, b)
+		t.FailNow()
+	}
+
+	// ConfigMaps are read back from the directory they are mounted in
+	b.Reset()
+	c.Export(b, ExportConfigMap)
+	var m struct{ Data map[string]string }
+	if err := yaml.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Logf("configmap: failed to read %v\n%s", err, b)
+		t.FailNow()
+	}
+	mount := filepath.Join(dir, "configmap")
+	os.Mkdir(mount, 0700)
+	for k, v := range m.Data {
+		os.WriteFile(filepath.Join(mount, k), []byte(v), 0600)
+	}
+	r = NewContext()
+	r.WithDirectory(mount)
+	if got, _ := r.resolvedDump(); !reflect.DeepEqual(got, want) {
+		t.Logf("configmap: expected %v, got %v from\n%s", want, got, b)
+		t.FailNow()
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	c := exportContext()
+	b := &bytes.Buffer{}
+	c.Export(b, ExportShell, Redact())
+	if !strings.Contains(b.String(), `export NAME='it'\''s'`) || !strings.Contains(b.String(), "export EP_API_SERVER_KEY='[REDACTED]'") {
+		t.Logf("unexpected shell export\n%s", b)
+		t.FailNow()
+	}
+
+	b.Reset()
+	c.Export(b, ExportSecret, ManifestName("svc"), Namespace("prod"))
+	var m struct {
+		Kind     string
+		Metadata map[string]string
+		Data     map[string]string
+	}
+	if err := yaml.Unmarshal(b.Bytes(), &m); err != nil || m.Kind != "Secret" || m.Metadata["name"] != "svc" || m.Metadata["namespace"] != "prod" {
+		t.Logf("unexpected secret %v\n%s", err, b)
+		t.FailNow()
+	}
+	if m.Data["EP_API_SERVER_KEY"] != "UEVN" {
+		t.Logf("expected the value base64 encoded, got %v", m.Data)
+		t.FailNow()
+	}
+
+	b.Reset()
+	c.ExportObjects(b, ExportJSON, Redact())
+	var objs map[string]string
+	if err := json.Unmarshal(b.Bytes(), &objs); err != nil || objs["ep_api_address"] != "localhost:1" || objs["ep_api_server_key"] != Redacted || objs["ep_api_client_key"] != "" {
+		t.Logf("unexpected objects %v\n%s", err, b)
+		t.FailNow()
+	}
+
+	b.Reset()
+	c.Export(b, ExportConfigMap)
+	first := b.String()
+	for i := 0; i < 5; i++ {
+		b.Reset()
+		c.Export(b, ExportConfigMap)
+		if b.String() != first {
+			t.Logf("expected a stable order\n%s\n%s", first, b)
+			t.FailNow()
+		}
+	}
+}
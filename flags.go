@@ -0,0 +1,110 @@
+package xvals
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// flagValue is the flag.Value of the flags generated by FlagSet
+type flagValue struct {
+	val    string
+	isBool bool
+}
+
+func (f *flagValue) String() string     { return f.val }
+func (f *flagValue) Set(s string) error { f.val = s; return nil }
+func (f *flagValue) IsBoolFlag() bool   { return f.isBool }
+
+// flagName converts a key to the name of its command line flag
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// flagKeyReplacer turns the separators of flag names into those of keys
+var flagKeyReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// flagKey converts the name of a command line flag back to a key. The case is
+// kept, it is up to the key normalizer of the context.
+func flagKey(name string) string {
+	return flagKeyReplacer.Replace(name)
+}
+
+// envName converts a key to the name of its environment variable
+func envName(key string) string {
+	return strings.ToUpper(key)
+}
+
+// flagInfo is a generated flag and the help shown for it
+type flagInfo struct {
+	key, def, descr string
+}
+
+// FlagSet generates a flag set with one flag for every key declared in the
+// schema of the context, and one for every field of the objects in the
+// object store. The usage of the flag set shows for every flag the
+// environment variable and config file key that set the same value, and the
+// default. The fields of registered descriptors are listed as well, so that
+// objects not yet known can be configured.
+//
+// The flags only provide values that are set on the command line, add the
+// parsed flag set to the context with WithFlagSet:
+//
+//	fs := c.FlagSet(os.Args[0], flag.ExitOnError)
+//	fs.Parse(os.Args[1:])
+//	c.WithFlagSet(fs)
+func (c *Context) FlagSet(name string, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, errorHandling)
+	var infos []flagInfo
+	add := func(key, def, descr string, isBool bool) {
+		if fs.Lookup(flagName(key)) != nil {
+			return
+		}
+		fs.Var(&flagValue{val: def, isBool: isBool}, flagName(key), descr)
+		infos = append(infos, flagInfo{key, def, descr})
+	}
+	if s := c.Schema(); s != nil {
+		for _, spec := range s.Specs() {
+			add(spec.Name, spec.Default, spec.Description, spec.Type == TypeBool)
+		}
+	}
+	objs := c.Objects()
+	keys := make([]string, 0, len(objs))
+	for k := range objs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		typ, name := FromKey(k)
+		fields := objs[k].Fields()
+		for _, f := range c.objects.fields(typ) {
+			add(strings.ToLower(typ+"_"+name+"_"+f), fields[tu(f)], fmt.Sprintf("%s of %s %s", f, typ, name), false)
+		}
+	}
+	descrs := c.objects.Descriptors()
+	fs.Usage = func() {
+		w := tabwriter.NewWriter(fs.Output(), 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Usage of %s:\n", fs.Name())
+		fmt.Fprintf(w, "  FLAG\tENVIRONMENT\tCONFIG KEY\tDEFAULT\tDESCRIPTION\n")
+		for _, i := range infos {
+			fmt.Fprintf(w, "  --%s\t%s\t%s\t%s\t%s\n", flagName(i.key), envName(i.key), i.key, i.def, i.descr)
+		}
+		for _, d := range descrs {
+			fmt.Fprintf(w, "\n  %s objects:\n", d.Type())
+			for _, f := range d.Fields() {
+				key := strings.ToLower(d.Type()) + "_<name>_" + strings.ToLower(f)
+				fmt.Fprintf(w, "  --%s\t%s\t%s\t\t\n", flagName(key), envName(key), key)
+			}
+		}
+		w.Flush()
+	}
+	return fs
+}
+
+// FlagSet generates a flag set for the keys and objects of the default
+// context.
+func FlagSet(name string, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	return defaultContext.FlagSet(name, errorHandling)
+}
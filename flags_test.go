@@ -0,0 +1,60 @@
+package xvals
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestFlagSet(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{"ep_api_address": "localhost:1", "db_port": "1"})
+	c.WithSchema(NewSchema(
+		KeySpec{Name: "db_port", Type: TypeInt, Default: "5432", Description: "port of the database"},
+		KeySpec{Name: "debug", Type: TypeBool},
+	))
+	c.ReloadObjects()
+
+	fs := c.FlagSet("svc", flag.ContinueOnError)
+	out := &bytes.Buffer{}
+	fs.SetOutput(out)
+	fs.Usage()
+	for _, exp := range []string{
+		"--db-port", "DB_PORT", "db_port", "5432", "port of the database",
+		"--ep-api-address", "EP_API_ADDRESS", "localhost:1",
+		"--ep-<name>-address", "EP_<NAME>_ADDRESS", "ep_<name>_address",
+	} {
+		if !strings.Contains(out.String(), exp) {
+			t.Logf("expected %q in usage:\n%s", exp, out)
+			t.FailNow()
+		}
+	}
+
+	if e := fs.Parse([]string{"--debug", "--ep-api-address=host:2"}); e != nil {
+		t.Logf("parse failed %v", e)
+		t.FailNow()
+	}
+	c.WithFlagSet(fs)
+	GetGoodC(t, c, "debug", "true")
+	GetGoodC(t, c, "ep_api_address", "host:2")
+	GetGoodC(t, c, "db_port", "1")
+}
+
+func TestFlagsStrictKeys(t *testing.T) {
+	c := NewContext()
+	c.WithKeyNormalizer(StrictKeys)
+	c.WithSchema(NewSchema(KeySpec{Name: "db_port", Type: TypeInt, Default: "5432"}))
+	c.WithArgs([]string{"--api-host=h", "--log.level=debug"})
+	GetGoodC(t, c, "api_host", "h")
+	GetGoodC(t, c, "log_level", "debug")
+
+	fs := c.FlagSet("svc", flag.ContinueOnError)
+	if e := fs.Parse([]string{"--db-port=6543"}); e != nil {
+		t.Logf("parse failed %v", e)
+		t.FailNow()
+	}
+	c.WithFlagSet(fs)
+	GetGoodC(t, c, "db_port", "6543")
+}
@@ -0,0 +1,157 @@
+package xvals
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat is the format of a config file
+type ConfigFormat int
+
+// The supported config file formats. FormatAuto selects the format from the
+// extension of the file, and defaults to YAML.
+const (
+	FormatAuto ConfigFormat = iota
+	FormatYAML
+	FormatJSON
+	FormatTOML
+)
+
+func (f ConfigFormat) String() string {
+	switch f {
+	case FormatAuto:
+		return "auto"
+	case FormatYAML:
+		return "yaml"
+	case FormatJSON:
+		return "json"
+	case FormatTOML:
+		return "toml"
+	default:
+		return fmt.Sprintf("ConfigFormat(%d)", int(f))
+	}
+}
+
+// formatOf returns the format of filename, based on its extension
+func formatOf(filename string) ConfigFormat {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// ConfigFileOption configures how WithConfigFile reads a file
+type ConfigFileOption func(*configFileProvider)
+
+// Format sets the format of the config file, instead of selecting it from
+// the extension of the file.
+func Format(f ConfigFormat) ConfigFileOption {
+	return func(c *configFileProvider) {
+		c.format = f
+	}
+}
+
+// Separator sets the separator used to join the names of nested maps and
+// lists into keys. The default is "_", which makes
+//
+//	ep:
+//	  api:
+//	    address: localhost:1
+//
+// the key ep_api_address.
+func Separator(sep string) ConfigFileOption {
+	return func(c *configFileProvider) {
+		c.sep = sep
+	}
+}
+
+// decodeConfig decodes data in the given format and flattens it into keys.
+func decodeConfig(data []byte, format ConfigFormat, sep string) (map[string]string, error) {
+	var (
+		doc map[string]interface{}
+		err error
+	)
+	switch format {
+	case FormatJSON:
+		// numbers are kept as written, a float64 can't hold every int64
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		if err = d.Decode(&doc); err == nil && d.More() {
+			err = fmt.Errorf("unexpected data after the JSON document")
+		}
+	case FormatTOML:
+		err = toml.Unmarshal(data, &doc)
+	default:
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	flatten(res, "", doc, sep)
+	return res, nil
+}
+
+// flatten stores the scalar values of v in res. Keys of maps and indexes of
+// lists are joined with sep to build the keys.
+func flatten(res map[string]string, prefix string, v interface{}, sep string) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + sep + name
+	}
+	switch t := v.(type) {
+	case nil:
+		res[prefix] = ""
+	case string:
+		res[prefix] = t
+	case bool:
+		res[prefix] = strconv.FormatBool(t)
+	case int:
+		res[prefix] = strconv.Itoa(t)
+	case int64:
+		res[prefix] = strconv.FormatInt(t, 10)
+	case json.Number:
+		res[prefix] = t.String()
+	case float64:
+		res[prefix] = strconv.FormatFloat(t, 'f', -1, 64)
+	case time.Time:
+		res[prefix] = t.Format(time.RFC3339Nano)
+	case map[string]interface{}:
+		for k, e := range t {
+			flatten(res, join(k), e, sep)
+		}
+	case []interface{}:
+		for i, e := range t {
+			flatten(res, join(strconv.Itoa(i)), e, sep)
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Map:
+			for _, k := range rv.MapKeys() {
+				flatten(res, join(fmt.Sprint(k.Interface())), rv.MapIndex(k).Interface(), sep)
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				flatten(res, join(strconv.Itoa(i)), rv.Index(i).Interface(), sep)
+			}
+		default:
+			res[prefix] = fmt.Sprint(v)
+		}
+	}
+}
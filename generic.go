@@ -0,0 +1,77 @@
+package xvals
+
+import "reflect"
+
+// Values is what the generic accessors read from, a *Context or a *View.
+type Values interface {
+	ParsedValue(key string, t reflect.Type) (interface{}, error)
+	values() *accessors
+}
+
+func (a *accessors) values() *accessors { return a }
+
+// get fetches key as a T. Types constructed by a descriptor, like *Endpoint,
+// are objects named key, other types are parsed like ParsedValue does.
+func get[T any](a *accessors, key string) (T, error) {
+	var zero T
+	t := typeOf[T]()
+	if typ, ok := a.ctx.objects.objectType(t); ok {
+		obj, err := a.ctx.GetObject(typ, a.prefix+key)
+		if err != nil {
+			return zero, err
+		}
+		return obj.(T), nil
+	}
+	v, err := a.ParsedValue(key, t)
+	if err != nil {
+		return zero, err
+	}
+	if v == nil {
+		return zero, nil
+	}
+	return v.(T), nil
+}
+
+// GetFrom fetches key from v as a T, parsed with the parser registered for T,
+// see RegisterParser, or as Bind would parse it. If T is the type of objects
+// of a descriptor added with WithObject, like *Endpoint, the object named key
+// is returned.
+//
+//	timeout, err := xvals.GetFrom[time.Duration](c, "timeout")
+//	api, err := xvals.GetFrom[*xvals.Endpoint](c, "api")
+func GetFrom[T any](v Values, key string) (T, error) {
+	return get[T](v.values(), key)
+}
+
+// GetOrFrom is GetFrom, returning def on errors.
+func GetOrFrom[T any](v Values, key string, def T) T {
+	res, err := GetFrom[T](v, key)
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+// MustGetFrom is GetFrom, panicking on errors.
+func MustGetFrom[T any](v Values, key string) T {
+	res, err := GetFrom[T](v, key)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// Get fetches key from the default context as a T, see GetFrom.
+func Get[T any](key string) (T, error) {
+	return GetFrom[T](defaultContext, key)
+}
+
+// GetOr is Get, returning def on errors.
+func GetOr[T any](key string, def T) T {
+	return GetOrFrom(defaultContext, key, def)
+}
+
+// MustGet is Get, panicking on errors.
+func MustGet[T any](key string) T {
+	return MustGetFrom[T](defaultContext, key)
+}
@@ -0,0 +1,75 @@
+package xvals
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{
+		"port":           "8080",
+		"timeout":        "5s",
+		"level":          "info",
+		"db_size":        "10",
+		"ep_api_address": "localhost:1",
+	})
+	c.ReloadObjects()
+
+	if p, err := GetFrom[int](c, "port"); err != nil || p != 8080 {
+		t.Logf("expected 8080, got %v %v", p, err)
+		t.FailNow()
+	}
+	if d := MustGetFrom[time.Duration](c, "timeout"); d != 5*time.Second {
+		t.Logf("expected 5s, got %v", d)
+		t.FailNow()
+	}
+	if n := GetOrFrom(c.Sub("db"), "size", 1); n != 10 {
+		t.Logf("expected 10 from the view, got %v", n)
+		t.FailNow()
+	}
+	if n := GetOrFrom(c, "missing", 7); n != 7 {
+		t.Logf("expected the default, got %v", n)
+		t.FailNow()
+	}
+	var pe *ParseError
+	if _, err := GetFrom[int](c, "timeout"); !errors.As(err, &pe) {
+		t.Logf("expected a ParseError, got %v", err)
+		t.FailNow()
+	}
+
+	levelType := reflect.TypeOf(testLevel(0))
+	RegisterParser(levelType, func(s string) (interface{}, error) {
+		if strings.ToLower(s) == "info" {
+			return testLevel(1), nil
+		}
+		return testLevel(0), nil
+	})
+	defer RegisterParser(levelType, nil)
+	if l, err := GetFrom[testLevel](c, "level"); err != nil || l != 1 {
+		t.Logf("expected level 1, got %v %v", l, err)
+		t.FailNow()
+	}
+
+	api, err := GetFrom[*Endpoint](c, "api")
+	if err != nil || api.Address != "localhost:1" {
+		t.Logf("expected the api endpoint, got %v %v", api, err)
+		t.FailNow()
+	}
+	if _, err := GetFrom[*Endpoint](c, "other"); !errors.Is(err, ErrNotFound) {
+		t.Logf("expected ErrNotFound, got %v", err)
+		t.FailNow()
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Logf("expected MustGetFrom to panic")
+			t.Fail()
+		}
+	}()
+	MustGetFrom[int](c, "missing")
+}
@@ -1,8 +1,9 @@
 module github.com/staffano/xvals
 
-go 1.17
+go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	google.golang.org/grpc v1.40.0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,134 @@
+package xvals
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Values can refer to other keys of the context:
+//
+//	ep_api_address: "${api_host}:${api_port}"
+//	db_url: "postgres://${db_user:-postgres}@${db_host}/app"
+//
+// A reference is replaced by the value of the key, which is looked up in the
+// whole provider chain and expanded in turn. ${key:-fallback} uses fallback
+// if the key isn't defined. $${ is an escaped ${ and is kept as a literal
+// ${ in the value.
+
+// expand replaces all references in val. stack holds the keys currently
+// being expanded and is used to detect cycles.
+func (c *Context) expand(val string, stack []string) (string, error) {
+	if !strings.Contains(val, "${") {
+		return val, nil
+	}
+	b := &strings.Builder{}
+	for i := 0; i < len(val); {
+		if strings.HasPrefix(val[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(val[i:], "${") {
+			b.WriteByte(val[i])
+			i++
+			continue
+		}
+		end := closingBrace(val, i+2)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference %q in value of %s", val[i:], stack[len(stack)-1])
+		}
+		r, err := c.resolveRef(val[i+2:end], stack)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(r)
+		i = end + 1
+	}
+	return b.String(), nil
+}
+
+// resolveRef returns the expanded value of a reference expression, which is
+// either "key" or "key:-fallback".
+func (c *Context) resolveRef(expr string, stack []string) (string, error) {
+	ref, def, hasDef := expr, "", false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		ref, def, hasDef = expr[:i], expr[i+2:], true
+	}
+	ref = c.normalize(strings.TrimSpace(ref))
+	for i, k := range stack {
+		if k == ref {
+			loop := append(stack[i:len(stack):len(stack)], ref)
+			return "", fmt.Errorf("interpolation cycle %s", strings.Join(loop, " -> "))
+		}
+	}
+	raw, err := c.rawValue(ref)
+	if err != nil {
+		if hasDef {
+			return c.expand(def, stack)
+		}
+		return "", fmt.Errorf("key %s referenced by %s %w", ref, stack[len(stack)-1], ErrNotFound)
+	}
+	return c.expand(raw, append(stack[:len(stack):len(stack)], ref))
+}
+
+// closingBrace returns the index of the brace closing a reference that starts
+// at i, taking nested references into account. Returns -1 if there is none.
+func closingBrace(val string, i int) int {
+	depth := 1
+	for ; i < len(val); i++ {
+		switch {
+		case strings.HasPrefix(val[i:], "${"):
+			depth++
+			i++
+		case val[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// escapeRefs escapes every ${ of val as $${, so that expanding val gives val
+func escapeRefs(val string) string {
+	return strings.ReplaceAll(val, "${", "$${")
+}
+
+// expandedDump returns Dump with the references expanded, like resolvedDump.
+// With escape, ${ in the expanded values is escaped, see escapeRefs.
+func (c *Context) expandedDump(escape bool) map[string]string {
+	res := c.Dump()
+	for k, v := range res {
+		r, err := c.expand(v, []string{k})
+		if err != nil {
+			continue
+		}
+		if escape {
+			r = escapeRefs(r)
+		}
+		res[k] = r
+	}
+	return res
+}
+
+// resolvedDump returns Dump with all references expanded. Values that fail to
+// expand are kept as they are and reported in the returned error.
+func (c *Context) resolvedDump() (map[string]string, error) {
+	res := c.Dump()
+	var errs []string
+	for k, v := range res {
+		r, err := c.expand(v, []string{k})
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		res[k] = r
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return res, fmt.Errorf("failed to expand values: %s", strings.Join(errs, ", "))
+	}
+	return res, nil
+}
@@ -0,0 +1,53 @@
+package xvals
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolation(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{
+		"api_host":       "localhost",
+		"api_port":       "8080",
+		"ep_api_address": "${api_host}:${API_PORT}",
+		"db_url":         "postgres://${db_user:-postgres}@${db_host:-${api_host}}/app",
+		"escaped":        "$${api_host} costs $5",
+		"loop_a":         "${loop_b}",
+		"loop_b":         "x${loop_a}",
+		"missing":        "${nope}",
+	})
+
+	tests := map[string]string{
+		"ep_api_address": "localhost:8080",
+		"db_url":         "postgres://postgres@localhost/app",
+		"escaped":        "${api_host} costs $5",
+	}
+	for k, exp := range tests {
+		if v, e := c.Value(k); e != nil || v != exp {
+			t.Logf("key: [%s] got:[%s] [%v] expected: [%s]", k, v, e, exp)
+			t.FailNow()
+		}
+	}
+
+	_, e := c.Value("loop_a")
+	if e == nil || !strings.Contains(e.Error(), "loop_a -> loop_b -> loop_a") {
+		t.Logf("expected cycle error, got %v", e)
+		t.FailNow()
+	}
+	if _, e := c.Value("missing"); e == nil {
+		t.Logf("expected error for missing reference")
+		t.FailNow()
+	}
+
+	if e := c.ReloadObjects(); e == nil {
+		t.Logf("expected ReloadObjects to report the failing keys")
+		t.FailNow()
+	}
+	ep, e := c.GetEndpoint("api")
+	if e != nil || ep.Address != "localhost:8080" {
+		t.Logf("unexpected endpoint %v %v", ep, e)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,186 @@
+package xvals
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A KeyNormalizer maps a key, as found in a source or asked for by Value, to
+// the key it is stored under in a context. Two keys are the same key if they
+// normalize to the same string.
+type KeyNormalizer func(key string) string
+
+// LooseKeys treats case and the separators '-', '.' and '_' as equivalent,
+// so that gurk-meja in a YAML file and GURK_MEJA in the environment are the
+// same key. Keys are lower cased and separators become '_'. This is the
+// default normalizer of a context.
+func LooseKeys(key string) string {
+	return looseReplacer.Replace(strings.ToLower(key))
+}
+
+var looseReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// StrictKeys only ignores case, keys are lower cased.
+func StrictKeys(key string) string {
+	return strings.ToLower(key)
+}
+
+// WithKeyNormalizer sets how the context normalizes keys. It is applied to
+// the keys of all providers, including those already added, to the keys
+// asked for, and to the names of objects. A nil normalizer restores the
+// default, LooseKeys.
+func (c *Context) WithKeyNormalizer(n KeyNormalizer) {
+	if n == nil {
+		n = LooseKeys
+	}
+	c.normalizer.Store(n)
+	for _, p := range c.chain() {
+		if r, ok := p.(renormalizer); ok {
+			r.renormalize()
+		}
+	}
+}
+
+// KeyNormalizer returns the key normalizer of the context.
+func (c *Context) KeyNormalizer() KeyNormalizer {
+	if n, ok := c.normalizer.Load().(KeyNormalizer); ok {
+		return n
+	}
+	return LooseKeys
+}
+
+// normalize returns key normalized by the key normalizer of the context
+func (c *Context) normalize(key string) string {
+	return c.KeyNormalizer()(key)
+}
+
+// WithKeyNormalizer sets how the default context normalizes keys.
+func WithKeyNormalizer(n KeyNormalizer) {
+	defaultContext.WithKeyNormalizer(n)
+}
+
+// A renormalizer normalizes its keys again when the key normalizer of its
+// context has changed.
+type renormalizer interface {
+	renormalize()
+}
+
+// providerContext links a provider to the context it has been added to, for
+// logging and key normalization.
+type providerContext struct {
+	link atomic.Value // providerLink
+}
+
+type providerLink struct {
+	ctx  *Context
+	self XvalProvider // the provider embedding the providerContext
+	name string       // name of the provider in the chain of ctx
+}
+
+// attachTo links the provider self, named name, to c
+func (p *providerContext) attachTo(c *Context, self XvalProvider, name string) {
+	p.link.Store(providerLink{c, self, name})
+}
+
+// chainName returns the name of the provider in the chain of its context,
+// empty if it has not been added to a context.
+func (p *providerContext) chainName() string {
+	l, _ := p.link.Load().(providerLink)
+	return l.name
+}
+
+// log returns the logger of the provider
+func (p *providerContext) log() Logger {
+	if l, ok := p.link.Load().(providerLink); ok {
+		return l.ctx.Logger()
+	}
+	return defaultLogger
+}
+
+// normalizer returns the key normalizer of the context of the provider, nil
+// if it has not been added to a context.
+func (p *providerContext) normalizer() KeyNormalizer {
+	if l, ok := p.link.Load().(providerLink); ok {
+		return l.ctx.KeyNormalizer()
+	}
+	return nil
+}
+
+// normalizeKeys returns raw with the keys normalized by n. When several keys
+// of raw normalize to the same key, the first of them in sorted order is
+// used, and the colliding keys are reported to collide.
+func normalizeKeys(raw map[string]string, n KeyNormalizer, collide func(key string, keys []string)) map[string]string {
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make(map[string]string, len(raw))
+	from := make(map[string][]string)
+	for _, k := range keys {
+		nk := n(k)
+		from[nk] = append(from[nk], k)
+		if _, ok := res[nk]; !ok {
+			res[nk] = raw[k]
+		}
+	}
+	for _, k := range keys {
+		nk := n(k)
+		if len(from[nk]) > 1 && from[nk][0] == k {
+			collide(nk, from[nk])
+		}
+	}
+	return res
+}
+
+// normalizedValues holds the values of a provider, both as read from the
+// source and with the keys normalized for its context.
+type normalizedValues struct {
+	providerContext
+	mu   sync.Mutex   // serializes normalizations
+	raw  atomic.Value // map[string]string, keys as read from the source
+	vals atomic.Value // map[string]string, never modified once stored
+}
+
+// load returns the current snapshot of the normalized values.
+func (v *normalizedValues) load() map[string]string {
+	vals, _ := v.vals.Load().(map[string]string)
+	return vals
+}
+
+// store publishes a new snapshot of the values. vals must not be modified
+// after it has been stored.
+func (v *normalizedValues) store(vals map[string]string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.raw.Store(vals)
+	v.vals.Store(v.normalized(vals))
+}
+
+// renormalize normalizes the values again, with the current key normalizer
+// of the context.
+func (v *normalizedValues) renormalize() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	raw, ok := v.raw.Load().(map[string]string)
+	if !ok {
+		return
+	}
+	v.vals.Store(v.normalized(raw))
+}
+
+// normalized returns raw normalized for the context of the provider, and
+// logs colliding keys. raw is returned as it is if the provider has not been
+// added to a context.
+func (v *normalizedValues) normalized(raw map[string]string) map[string]string {
+	n := v.normalizer()
+	if n == nil {
+		return raw
+	}
+	return normalizeKeys(raw, n, func(key string, keys []string) {
+		l, _ := v.link.Load().(providerLink)
+		v.log().Warn("keys collide after normalization, using the first", append(logArgs(l.self), "key", key, "keys", keys)...)
+	})
+}
@@ -0,0 +1,60 @@
+package xvals
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyNormalization(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithConfigFile("testdata/testctx1.yaml")
+	c.WithMap(map[string]string{"ep_my_api.address": "localhost:1"})
+
+	for _, k := range []string{"gurk-meja", "GURK_MEJA", "gurk.meja"} {
+		GetGoodC(t, c, k, "växt")
+	}
+	c.ReloadObjects()
+	if ep, err := c.GetEndpoint("my-api"); err != nil || ep.Address != "localhost:1" {
+		t.Logf("unexpected endpoint %v %v", ep, err)
+		t.FailNow()
+	}
+
+	c.WithKeyNormalizer(StrictKeys)
+	GetGoodC(t, c, "GURK-MEJA", "växt")
+	if c.HasValue("gurk_meja") {
+		t.Logf("expected gurk_meja to be a different key with strict keys")
+		t.FailNow()
+	}
+}
+
+func TestKeyCollisions(t *testing.T) {
+	rl := &recordLogger{}
+	c := NewContext()
+	c.WithLogger(rl)
+	c.WithMap(map[string]string{"db-host": "a", "DB_HOST": "b", "port": "1"})
+	GetGoodC(t, c, "db.host", "b")
+	if len(rl.msgs) != 1 || !strings.Contains(rl.msgs[0], "key db_host keys [DB_HOST db-host]") {
+		t.Logf("expected the collision to be logged, got %q", rl.msgs)
+		t.FailNow()
+	}
+}
+
+func TestKeyCollisionsInFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db.yaml")
+	if err := os.WriteFile(file, []byte("DB_HOST: a\ndb_host: b\ndb-host: c\n"), 0o600); err != nil {
+		t.Logf("failed to write %s: %v", file, err)
+		t.FailNow()
+	}
+	rl := &recordLogger{}
+	c := NewContext()
+	c.WithLogger(rl)
+	c.WithConfigFile(file)
+	GetGoodC(t, c, "db_host", "a")
+	if len(rl.msgs) != 1 || !strings.Contains(rl.msgs[0], "key db_host keys [DB_HOST db-host db_host]") {
+		t.Logf("expected the collision of the three keys to be logged, got %q", rl.msgs)
+		t.FailNow()
+	}
+}
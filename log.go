@@ -0,0 +1,140 @@
+package xvals
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives the log messages of a Context. args are alternating keys
+// and values, like with log/slog, so a *slog.Logger can be used as it is.
+//
+// The keys used are "provider" for the kind of provider, "name" for its name
+// in the chain of the context, "path" for the file or directory of a
+// provider, "source" for other sources, "key" for a key, "endpoint" for the
+// name of an endpoint and "error" for errors.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Level of a log message
+type Level int
+
+// Log levels, in increasing severity
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// NewStdLogger returns a Logger that writes the messages of level min and
+// above to l, as "LEVEL msg key=value ...".
+func NewStdLogger(l *log.Logger, min Level) Logger {
+	return &stdLogger{l: l, min: min}
+}
+
+// DiscardLogger is a Logger that drops all messages.
+var DiscardLogger Logger = discardLogger{}
+
+// defaultLogger is used by contexts without a logger. It writes warnings and
+// errors with the standard log package, like the package always has.
+var defaultLogger = NewStdLogger(log.Default(), LevelWarn)
+
+// stdLogger writes messages to a *log.Logger
+type stdLogger struct {
+	l   *log.Logger
+	min Level
+}
+
+func (s *stdLogger) Debug(msg string, args ...interface{}) { s.log(LevelDebug, msg, args) }
+func (s *stdLogger) Info(msg string, args ...interface{})  { s.log(LevelInfo, msg, args) }
+func (s *stdLogger) Warn(msg string, args ...interface{})  { s.log(LevelWarn, msg, args) }
+func (s *stdLogger) Error(msg string, args ...interface{}) { s.log(LevelError, msg, args) }
+
+func (s *stdLogger) log(level Level, msg string, args []interface{}) {
+	if level < s.min {
+		return
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s %s", level, msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(b, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(b, " %v=%v", args[i], args[i+1])
+	}
+	s.l.Print(b.String())
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Debug(string, ...interface{}) {}
+func (discardLogger) Info(string, ...interface{})  {}
+func (discardLogger) Warn(string, ...interface{})  {}
+func (discardLogger) Error(string, ...interface{}) {}
+
+// loggerBox lets loggers of different types be stored in an atomic.Value
+type loggerBox struct {
+	Logger
+}
+
+// WithLogger sets the logger of the context, used by its providers, objects
+// and AutoReload. A nil logger restores the default, which writes warnings and
+// errors with the standard log package.
+func (c *Context) WithLogger(l Logger) {
+	c.logger.Store(loggerBox{l})
+}
+
+// Logger returns the logger of the context.
+func (c *Context) Logger() Logger {
+	if b, ok := c.logger.Load().(loggerBox); ok && b.Logger != nil {
+		return b.Logger
+	}
+	return defaultLogger
+}
+
+// WithLogger sets the logger of the default context.
+func WithLogger(l Logger) {
+	defaultContext.WithLogger(l)
+}
+
+// logArgs returns the fields describing p
+func logArgs(p XvalProvider) []interface{} {
+	kind, source := describe(p)
+	args := []interface{}{"provider", kind}
+	if n, ok := p.(interface{ chainName() string }); ok && n.chainName() != "" {
+		args = append(args, "name", n.chainName())
+	}
+	if fp, ok := p.(fileProvider); ok {
+		path, _ := fp.watchTarget()
+		return append(args, "path", path)
+	}
+	if source != "" {
+		args = append(args, "source", source)
+	}
+	return args
+}
+
+// logReloadError logs that p failed to reload, and kept its previous values
+func logReloadError(l Logger, p XvalProvider, err error) {
+	l.Error("failed to reload provider, keeping previous values", append(logArgs(p), "error", err)...)
+}
@@ -0,0 +1,76 @@
+package xvals
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordLogger keeps the messages logged
+type recordLogger struct {
+	msgs []string
+}
+
+func (r *recordLogger) record(level, msg string, args []interface{}) {
+	r.msgs = append(r.msgs, fmt.Sprint(level, " ", msg, " ", args))
+}
+
+func (r *recordLogger) Debug(msg string, args ...interface{}) { r.record("DEBUG", msg, args) }
+func (r *recordLogger) Info(msg string, args ...interface{})  { r.record("INFO", msg, args) }
+func (r *recordLogger) Warn(msg string, args ...interface{})  { r.record("WARN", msg, args) }
+func (r *recordLogger) Error(msg string, args ...interface{}) { r.record("ERROR", msg, args) }
+
+func TestLogger(t *testing.T) {
+	env := filepath.Join(t.TempDir(), ".env")
+	rl := &recordLogger{}
+	c := NewContext()
+	c.WithLogger(rl)
+
+	c.WithDotenv(env)
+	exp := fmt.Sprintf("ERROR failed to load provider [provider dotenv name dotenv:%s path %s error", env, env)
+	if len(rl.msgs) != 1 || rl.msgs[0][:len(exp)] != exp {
+		t.Logf("unexpected messages %q", rl.msgs)
+		t.FailNow()
+	}
+
+	rl2 := &recordLogger{}
+	c.WithLogger(rl2)
+	for _, p := range c.chain() {
+		p.Reload()
+	}
+	if len(rl2.msgs) != 1 || len(rl.msgs) != 1 {
+		t.Logf("expected the reload failure to be logged with the new logger, got %q and %q", rl.msgs, rl2.msgs)
+		t.FailNow()
+	}
+
+	os.WriteFile(env, []byte("EP_API_ADDRESS=localhost:1\nEP_API_TLS=server\nEP_API_SERVER_CACERT=external\n"), 0644)
+	c.WithObject(EndpointDescr)
+	c.Reload()
+	ep, err := c.GetEndpoint("api")
+	if err != nil || ep.name != "API" || ep.log() != rl2 {
+		t.Logf("expected the endpoint to have a name and the logger, got %v %v", ep, err)
+		t.FailNow()
+	}
+	rl3 := &recordLogger{}
+	c.WithLogger(rl3)
+	if ep.log() != rl3 {
+		t.Logf("expected the endpoint to use the new logger")
+		t.FailNow()
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	b := &bytes.Buffer{}
+	l := NewStdLogger(log.New(b, "", 0), LevelInfo)
+	l.Debug("hidden")
+	l.Info("reloaded", "provider", "file", "path", "/etc/app.yaml")
+	l.Error("odd", "key")
+	exp := "INFO reloaded provider=file path=/etc/app.yaml\nERROR odd !BADKEY=key\n"
+	if b.String() != exp {
+		t.Logf("expected %q, got %q", exp, b.String())
+		t.FailNow()
+	}
+}
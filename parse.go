@@ -0,0 +1,208 @@
+package xvals
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// parsers are the parsers registered with RegisterParser
+var parsers = struct {
+	sync.RWMutex
+	m map[reflect.Type]func(string) (interface{}, error)
+}{m: make(map[reflect.Type]func(string) (interface{}, error))}
+
+// RegisterParser registers fn as the parser of values of type t, used by the
+// typed accessors, like DurationValue for time.Duration, by ParsedValue and
+// by Bind. fn must return a value of type t. A parser registered for a type
+// with built in support replaces the built in parser, registering nil
+// restores it.
+//
+//	xvals.RegisterParser(reflect.TypeOf(slog.Level(0)), func(s string) (interface{}, error) {
+//		var l slog.Level
+//		err := l.UnmarshalText([]byte(s))
+//		return l, err
+//	})
+func RegisterParser(t reflect.Type, fn func(string) (interface{}, error)) {
+	parsers.Lock()
+	defer parsers.Unlock()
+	if fn == nil {
+		delete(parsers.m, t)
+		return
+	}
+	parsers.m[t] = fn
+}
+
+// registeredParser returns the parser registered for t
+func registeredParser(t reflect.Type) (func(string) (interface{}, error), bool) {
+	parsers.RLock()
+	defer parsers.RUnlock()
+	fn, ok := parsers.m[t]
+	return fn, ok
+}
+
+// parseRegistered parses s with the parser registered for t, checking that
+// it returns a t.
+func parseRegistered(fn func(string) (interface{}, error), t reflect.Type, s string) (reflect.Value, error) {
+	v, err := fn(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Type() != t && (t.Kind() != reflect.Interface || !rv.Type().Implements(t)) {
+		return reflect.Value{}, fmt.Errorf("parser for %s returned %T", t, v)
+	}
+	return rv, nil
+}
+
+// parseBool parses a boolean, ignoring case. true, t, yes, y, on and 1 are
+// true, false, f, no, n, off and 0 are false.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "yes", "y", "on", "1":
+		return true, nil
+	case "false", "f", "no", "n", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
+
+// sizeUnits are the multipliers of the units of byte sizes, lower cased
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"p":   1e15,
+	"pb":  1e15,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+	"pi":  1 << 50,
+	"pib": 1 << 50,
+}
+
+// parseSize parses a byte size like "512", "64MiB", "1.5GB" or "10k". SI
+// units (k, KB, M, MB, ...) are powers of 1000 and IEC units (Ki, KiB, Mi,
+// MiB, ...) powers of 1024. Units are case insensitive.
+func parseSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) })
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = strings.TrimSpace(s[:i]), s[i:]
+	}
+	mul, ok := sizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q in %q", unit, s)
+	}
+	if u, err := strconv.ParseUint(num, 10, 64); err == nil && mul == 1 {
+		return u, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	size := f * mul
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("size %q out of range", s)
+	}
+	return uint64(size), nil
+}
+
+// timeLayouts are the layouts tried by parseTime, in order
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseTime parses a time in RFC 3339 format, or as a date and time without
+// time zone, which is then UTC, or as a date.
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or a date like 2006-01-02", s)
+}
+
+// parseURL parses an absolute URL
+func parseURL(s string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%q is not an absolute URL", s)
+	}
+	return u, nil
+}
+
+// parseIP parses an IPv4 or IPv6 address
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", s)
+	}
+	return ip, nil
+}
+
+// parseCIDR parses a network in CIDR notation, like 10.0.0.0/8
+func parseCIDR(s string) (*net.IPNet, error) {
+	_, n, err := net.ParseCIDR(strings.TrimSpace(s))
+	return n, err
+}
+
+// splitList splits a comma separated list, trimming the items. A comma that
+// is part of an item is escaped as \, and a backslash as \\. An empty string
+// is an empty list.
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var items []string
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == ',' || s[i+1] == '\\'):
+			i++
+			b.WriteByte(s[i])
+		case s[i] == ',':
+			items = append(items, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(items, strings.TrimSpace(b.String()))
+}
+
+// parseMap parses a comma separated list of key=value pairs, see splitList.
+func parseMap(s string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, item := range splitList(s) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%q is not a key=value pair", item)
+		}
+		res[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return res, nil
+}
@@ -0,0 +1,289 @@
+package xvals
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyType is the type a value of a key in a Schema must have
+type KeyType int
+
+// The types of keys a Schema can validate
+const (
+	TypeString KeyType = iota
+	TypeInt
+	TypeBool
+	TypeDuration
+	TypeURL
+	TypeEnum
+)
+
+func (t KeyType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeBool:
+		return "bool"
+	case TypeDuration:
+		return "duration"
+	case TypeURL:
+		return "url"
+	case TypeEnum:
+		return "enum"
+	default:
+		return fmt.Sprintf("KeyType(%d)", int(t))
+	}
+}
+
+// KeySpec declares a key that a service uses.
+type KeySpec struct {
+	Name        string
+	Type        KeyType
+	Default     string // used if no provider has the key, empty for no default
+	Required    bool
+	Min, Max    string   // inclusive bounds for int and duration keys, empty is unbounded
+	Pattern     string   // regular expression the value must match
+	OneOf       []string // allowed values, the set of values for TypeEnum
+	Description string
+	Secret      bool // the value is redacted by RedactedDump, see IsSecret
+}
+
+// A Schema is a set of declared keys. It is used by a Context to validate
+// the values of its providers and to provide default values.
+type Schema struct {
+	mu    sync.RWMutex
+	specs []KeySpec
+	index map[string]int
+}
+
+// NewSchema creates a schema with the given keys.
+func NewSchema(specs ...KeySpec) *Schema {
+	s := &Schema{index: make(map[string]int)}
+	return s.Add(specs...)
+}
+
+// Add declares keys in the schema. A key that is already declared is
+// replaced. Returns the schema to allow chaining.
+func (s *Schema) Add(specs ...KeySpec) *Schema {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, spec := range specs {
+		spec.Name = strings.ToLower(spec.Name)
+		if i, ok := s.index[spec.Name]; ok {
+			s.specs[i] = spec
+			continue
+		}
+		s.index[spec.Name] = len(s.specs)
+		s.specs = append(s.specs, spec)
+	}
+	return s
+}
+
+// Specs returns the declared keys in the order they were added.
+func (s *Schema) Specs() []KeySpec {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]KeySpec, len(s.specs))
+	copy(res, s.specs)
+	return res
+}
+
+// Lookup returns the declaration of a key.
+func (s *Schema) Lookup(key string) (KeySpec, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.index[strings.ToLower(key)]
+	if !ok {
+		return KeySpec{}, false
+	}
+	return s.specs[i], true
+}
+
+// defaults returns the default values of the declared keys.
+func (s *Schema) defaults() map[string]string {
+	res := make(map[string]string)
+	if s == nil {
+		return res
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, spec := range s.specs {
+		if spec.Default != "" {
+			res[spec.Name] = spec.Default
+		}
+	}
+	return res
+}
+
+// A Violation is a key whose value doesn't follow its declaration.
+type Violation struct {
+	Key     string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Key, v.Message)
+}
+
+// ValidationError is returned by Validate and holds every violation found.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "configuration has %d error(s):", len(e.Violations))
+	for _, v := range e.Violations {
+		fmt.Fprintf(b, "\n\t%s", v)
+	}
+	return b.String()
+}
+
+// check validates val against the declaration. Returns an empty string if
+// the value is valid.
+func (spec *KeySpec) check(val string) string {
+	switch spec.Type {
+	case TypeInt:
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Sprintf("value %q is not an int", val)
+		}
+		if msg := checkRange(spec, float64(i), func(s string) (float64, error) {
+			b, err := strconv.Atoi(s)
+			return float64(b), err
+		}); msg != "" {
+			return msg
+		}
+	case TypeBool:
+		if _, err := parseBool(val); err != nil {
+			return fmt.Sprintf("value %q is not a bool", val)
+		}
+	case TypeDuration:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Sprintf("value %q is not a duration", val)
+		}
+		if msg := checkRange(spec, float64(d), func(s string) (float64, error) {
+			b, err := time.ParseDuration(s)
+			return float64(b), err
+		}); msg != "" {
+			return msg
+		}
+	case TypeURL:
+		u, err := url.Parse(val)
+		if err != nil || u.Scheme == "" {
+			return fmt.Sprintf("value %q is not an absolute url", val)
+		}
+	case TypeEnum:
+		if len(spec.OneOf) == 0 {
+			return "enum declared without values"
+		}
+	case TypeString:
+	default:
+		return fmt.Sprintf("unknown type %s", spec.Type)
+	}
+	if len(spec.OneOf) > 0 && !contains(spec.OneOf, val) {
+		return fmt.Sprintf("value %q is not one of %s", val, strings.Join(spec.OneOf, ", "))
+	}
+	if spec.Pattern != "" {
+		re, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return fmt.Sprintf("invalid pattern %q: %v", spec.Pattern, err)
+		}
+		if !re.MatchString(val) {
+			return fmt.Sprintf("value %q does not match %s", val, spec.Pattern)
+		}
+	}
+	return ""
+}
+
+// checkRange checks that v is within the bounds of spec. The bounds are
+// parsed with parse.
+func checkRange(spec *KeySpec, v float64, parse func(string) (float64, error)) string {
+	if spec.Min != "" {
+		min, err := parse(spec.Min)
+		if err != nil {
+			return fmt.Sprintf("invalid min bound %q", spec.Min)
+		}
+		if v < min {
+			return fmt.Sprintf("value is less than %s", spec.Min)
+		}
+	}
+	if spec.Max != "" {
+		max, err := parse(spec.Max)
+		if err != nil {
+			return fmt.Sprintf("invalid max bound %q", spec.Max)
+		}
+		if v > max {
+			return fmt.Sprintf("value is greater than %s", spec.Max)
+		}
+	}
+	return ""
+}
+
+func contains(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSchema sets the schema of the context. The defaults of the schema are
+// used for keys that no provider has.
+func (c *Context) WithSchema(s *Schema) {
+	c.schema.Store(s)
+}
+
+// Schema returns the schema of the context, nil if none is set.
+func (c *Context) Schema() *Schema {
+	s, _ := c.schema.Load().(*Schema)
+	return s
+}
+
+// Validate checks the values of the context against its schema. All
+// violations are returned at once in a *ValidationError.
+func (c *Context) Validate() error {
+	s := c.Schema()
+	if s == nil {
+		return nil
+	}
+	var violations []Violation
+	for _, spec := range s.Specs() {
+		val, err := c.Value(spec.Name)
+		if err != nil {
+			if _, rawErr := c.rawValue(spec.Name); rawErr == nil {
+				// defined, but the references couldn't be expanded
+				violations = append(violations, Violation{spec.Name, err.Error()})
+			} else if spec.Required {
+				violations = append(violations, Violation{spec.Name, "required key is missing"})
+			}
+			continue
+		}
+		if msg := spec.check(val); msg != "" {
+			violations = append(violations, Violation{spec.Name, msg})
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// WithSchema sets the schema of the default context.
+func WithSchema(s *Schema) {
+	defaultContext.WithSchema(s)
+}
+
+// Validate checks the values of the default context against its schema.
+func Validate() error {
+	return defaultContext.Validate()
+}
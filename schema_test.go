@@ -0,0 +1,54 @@
+package xvals
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	c := NewContext()
+	c.WithMap(map[string]string{
+		"db_port":   "70000",
+		"timeout":   "10s",
+		"log_level": "loud",
+		"db_url":    "localhost",
+		"name":      "Svc",
+	})
+	c.WithSchema(NewSchema(
+		KeySpec{Name: "db_port", Type: TypeInt, Min: "1", Max: "65535"},
+		KeySpec{Name: "db_host", Type: TypeString, Required: true},
+		KeySpec{Name: "timeout", Type: TypeDuration, Max: "5s"},
+		KeySpec{Name: "log_level", Type: TypeEnum, OneOf: []string{"debug", "info"}},
+		KeySpec{Name: "db_url", Type: TypeURL},
+		KeySpec{Name: "name", Pattern: "^[a-z]+$"},
+		KeySpec{Name: "retries", Type: TypeInt, Default: "3"},
+		KeySpec{Name: "optional", Type: TypeBool},
+	))
+
+	e := c.Validate()
+	var ve *ValidationError
+	if !errors.As(e, &ve) {
+		t.Logf("expected ValidationError, got %v", e)
+		t.FailNow()
+	}
+	exp := []string{"db_port", "db_host", "timeout", "log_level", "db_url", "name"}
+	if len(ve.Violations) != len(exp) {
+		t.Logf("unexpected violations %v", e)
+		t.FailNow()
+	}
+	for i, k := range exp {
+		if ve.Violations[i].Key != k {
+			t.Logf("violation %d got:[%s] expected: [%s]", i, ve.Violations[i].Key, k)
+			t.FailNow()
+		}
+	}
+
+	if v, e := c.IntValue("retries"); e != nil || v != 3 {
+		t.Logf("expected default 3, got %d %v", v, e)
+		t.FailNow()
+	}
+	if c.Dump()["retries"] != "3" || c.Explain("retries").Winner.Kind != "schema" {
+		t.Logf("expected schema default in Dump and Explain")
+		t.FailNow()
+	}
+}
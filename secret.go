@@ -0,0 +1,161 @@
+package xvals
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Redacted replaces the values of secrets in RedactedDump and when a Secret
+// is printed or marshaled.
+const Redacted = "[REDACTED]"
+
+// DefaultSecretPatterns are the patterns of the keys that are secret unless
+// set otherwise with WithSecretPatterns.
+var DefaultSecretPatterns = []string{"*_key", "*password*", "*secret*", "*token*"}
+
+// A Secret is a value that must not end up in logs or dumps. It is redacted
+// when printed with the fmt package or marshaled to JSON or YAML. Use Reveal
+// to get the value.
+type Secret string
+
+// Reveal returns the value of the secret.
+func (s Secret) Reveal() string { return string(s) }
+
+// String returns Redacted
+func (s Secret) String() string { return Redacted }
+
+// Format prints Redacted, whatever the verb.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprintf(f, "%q", Redacted)
+		return
+	}
+	io.WriteString(f, Redacted)
+}
+
+// MarshalJSON marshals Redacted
+func (s Secret) MarshalJSON() ([]byte, error) { return json.Marshal(Redacted) }
+
+// MarshalYAML marshals Redacted
+func (s Secret) MarshalYAML() (interface{}, error) { return Redacted, nil }
+
+// SecretValue fetches a value as a Secret
+func (a *accessors) SecretValue(key string) (Secret, error) {
+	v, err := a.Value(key)
+	return Secret(v), err
+}
+
+// IsSecret tells whether the value of key is a secret. A key is secret if
+// it is declared Secret in the schema, if it is a secret field of an object,
+// see SecretDescriptor, or if it matches one of the secret patterns of the
+// context.
+func (a *accessors) IsSecret(key string) bool {
+	return a.ctx.isSecret(a.ctx.normalize(a.prefix + key))
+}
+
+// isSecret tells whether the normalized key is secret
+func (c *Context) isSecret(key string) bool {
+	if spec, ok := c.schemaSpec(key); ok && spec.Secret {
+		return true
+	}
+	if c.objects.secretField(key) {
+		return true
+	}
+	for _, p := range c.SecretPatterns() {
+		if ok, _ := path.Match(c.normalize(p), key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSecretPatterns sets the patterns of the keys of the context that are
+// secret, as used by path.Match, like *_key. The patterns are normalized
+// like keys. Without patterns only the schema and the object descriptors
+// decide which keys are secret.
+func (c *Context) WithSecretPatterns(patterns ...string) {
+	c.secrets.Store(append([]string{}, patterns...))
+}
+
+// SecretPatterns returns the patterns of the secret keys of the context.
+func (c *Context) SecretPatterns() []string {
+	if p, ok := c.secrets.Load().([]string); ok {
+		return p
+	}
+	return DefaultSecretPatterns
+}
+
+// RedactedDump returns Dump with the values of the secret keys replaced by
+// Redacted, empty values are kept. Use it rather than Dump for values that
+// may be logged.
+func (c *Context) RedactedDump() map[string]string {
+	return c.redact(c.Dump(), "")
+}
+
+// RedactedDump returns Dump with the values of the secret keys replaced by
+// Redacted.
+func (v *View) RedactedDump() map[string]string {
+	return v.ctx.redact(v.Dump(), v.prefix)
+}
+
+// redact replaces the values of kv whose keys, with prefix, are secret
+func (c *Context) redact(kv map[string]string, prefix string) map[string]string {
+	for k := range kv {
+		if kv[k] != "" && c.isSecret(prefix+k) {
+			kv[k] = Redacted
+		}
+	}
+	return kv
+}
+
+// secretField tells whether key is the key of a secret field of an object
+func (c *ObjectStore) secretField(key string) bool {
+	key = tu(key)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, d := range c.descriptors {
+		sd, ok := d.(SecretDescriptor)
+		if !ok {
+			continue
+		}
+		name, field := extractNameField(key, tu(d.Type()), d.Fields())
+		if name != "" && contains(upper(sd.SecretFields()), field) {
+			return true
+		}
+	}
+	return false
+}
+
+// upper returns vals upper cased
+func upper(vals []string) []string {
+	res := make([]string, len(vals))
+	for i, v := range vals {
+		res[i] = strings.ToUpper(v)
+	}
+	return res
+}
+
+// SecretValue fetches a value of the default context as a Secret.
+func SecretValue(key string) (Secret, error) {
+	return defaultContext.SecretValue(key)
+}
+
+// IsSecret tells whether the value of key in the default context is secret.
+func IsSecret(key string) bool {
+	return defaultContext.IsSecret(key)
+}
+
+// WithSecretPatterns sets the patterns of the secret keys of the default
+// context.
+func WithSecretPatterns(patterns ...string) {
+	defaultContext.WithSecretPatterns(patterns...)
+}
+
+// RedactedDump returns the values of the default context, with the values
+// of the secret keys redacted.
+func RedactedDump() map[string]string {
+	return defaultContext.RedactedDump()
+}
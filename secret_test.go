@@ -0,0 +1,69 @@
+package xvals
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSecret(t *testing.T) {
+	s := Secret("hunter2")
+	for _, out := range []string{s.String(), fmt.Sprint(s), fmt.Sprintf("%v %s %q %#v %+v", s, s, s, s, s)} {
+		if strings.Contains(out, "hunter2") {
+			t.Logf("secret printed as %s", out)
+			t.FailNow()
+		}
+	}
+	j, _ := json.Marshal(struct{ P Secret }{s})
+	y, _ := yaml.Marshal(struct{ P Secret }{s})
+	if strings.Contains(string(j), "hunter2") || strings.Contains(string(y), "hunter2") {
+		t.Logf("secret marshaled as %s %s", j, y)
+		t.FailNow()
+	}
+	if s.Reveal() != "hunter2" {
+		t.Logf("expected the value to be revealed, got %s", s.Reveal())
+		t.FailNow()
+	}
+}
+
+func TestRedactedDump(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithSchema(NewSchema(KeySpec{Name: "dsn", Secret: true}))
+	c.WithMap(map[string]string{
+		"name":              "svc",
+		"dsn":               "postgres://u:p@h/db",
+		"db_password":       "pw",
+		"api-key":           "k",
+		"ep_api_address":    "localhost:1",
+		"ep_api_server_key": "PEM",
+	})
+	kv := c.RedactedDump()
+	for _, k := range []string{"dsn", "db_password", "api_key", "ep_api_server_key"} {
+		if kv[k] != Redacted {
+			t.Logf("expected %s to be redacted, got %q", k, kv[k])
+			t.FailNow()
+		}
+	}
+	if kv["name"] != "svc" || kv["ep_api_address"] != "localhost:1" {
+		t.Logf("unexpected values %v", kv)
+		t.FailNow()
+	}
+	if kv := c.Sub("db").RedactedDump(); kv["password"] != Redacted {
+		t.Logf("expected the view to redact password, got %v", kv)
+		t.FailNow()
+	}
+	if v, err := c.SecretValue("db_password"); err != nil || v.Reveal() != "pw" {
+		t.Logf("expected pw, got %v", err)
+		t.FailNow()
+	}
+
+	c.WithSecretPatterns()
+	if c.IsSecret("db_password") || !c.IsSecret("dsn") || !c.IsSecret("EP_API_CLIENT_KEY") {
+		t.Logf("unexpected secrets without patterns")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,90 @@
+package xvals
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A View is the part of a context whose keys start with a prefix, seen
+// without the prefix. Sub("db").Value("host") is the value of db_host in
+// the context. A view has the same accessors as a context, and always sees
+// the current values of the context.
+//
+// A View is an XvalProvider, so it can be added to another context. Its
+// values are expanded in the context of the view, and are not expanded again
+// by the other context: an escaped $${key} stays a literal ${key}.
+type View struct {
+	accessors
+}
+
+// Sub returns a view of the keys of the context starting with prefix and a
+// separator, like db_ for the prefix db.
+func (c *Context) Sub(prefix string) *View {
+	return &View{accessors{ctx: c, prefix: c.normalize(prefix) + "_"}}
+}
+
+// Sub returns a view of the keys of the view starting with prefix, like
+// Context.Sub. Views nest, Sub("db").Sub("primary") is Sub("db_primary").
+func (v *View) Sub(prefix string) *View {
+	return &View{accessors{ctx: v.ctx, prefix: v.prefix + v.ctx.normalize(prefix) + "_"}}
+}
+
+// Prefix returns the prefix of the view, including the trailing separator.
+func (v *View) Prefix() string {
+	return v.prefix
+}
+
+// Dump returns the values of the view, without the prefix. Unlike
+// Context.Dump the values have their references expanded, since they may
+// refer to keys outside of the view. Values that fail to expand are returned
+// as they are.
+func (v *View) Dump() map[string]string {
+	return v.dump(false)
+}
+
+// dump returns the expanded values of the view, escaped with escape
+func (v *View) dump(escape bool) map[string]string {
+	res := make(map[string]string)
+	for k, val := range v.ctx.expandedDump(escape) {
+		if strings.HasPrefix(k, v.prefix) && len(k) > len(v.prefix) {
+			res[k[len(v.prefix):]] = val
+		}
+	}
+	return res
+}
+
+// chainValue returns the value of key as a context the view is added to
+// should expand it: expanded, with its ${ escaped so that it isn't expanded
+// again. A value that fails to expand is returned as it is.
+func (v *View) chainValue(key string) (string, error) {
+	r, _, err := v.ctx.rawLookup(v.prefix + key)
+	if err != nil {
+		return "", err
+	}
+	if val, err := v.ctx.expand(r, []string{v.ctx.normalize(v.prefix + key)}); err == nil {
+		return escapeRefs(val), nil
+	}
+	return r, nil
+}
+
+// chainDump returns Dump with the values escaped like chainValue.
+func (v *View) chainDump() map[string]string {
+	return v.dump(true)
+}
+
+// Reload does nothing, a view always sees the current values of its
+// context. Reload the context to reload its providers.
+func (v *View) Reload() {
+}
+
+// Kind returns "view"
+func (v *View) Kind() string { return "view" }
+
+// Source returns the prefix of the view as <prefix>*
+func (v *View) Source() string { return fmt.Sprintf("%s*", v.prefix) }
+
+// Sub returns a view of the keys of the default context starting with
+// prefix.
+func Sub(prefix string) *View {
+	return defaultContext.Sub(prefix)
+}
@@ -0,0 +1,62 @@
+package xvals
+
+import (
+	"testing"
+)
+
+func TestSub(t *testing.T) {
+	c := NewContext()
+	c.WithMap(map[string]string{
+		"db_host":         "localhost",
+		"db_port":         "5432",
+		"db_primary_user": "admin",
+		"db_url":          "${db_host}:${db_port}",
+		"db_note":         "$${user} is literal",
+		"dbx":             "not in view",
+		"cache_size":      "10",
+	})
+
+	db := c.Sub("db")
+	GetGoodC(t, c, "db_host", "localhost")
+	if v, err := db.Value("host"); err != nil || v != "localhost" {
+		t.Logf("expected localhost, got %s %v", v, err)
+		t.FailNow()
+	}
+	if v, err := db.IntValue("port"); err != nil || v != 5432 {
+		t.Logf("expected 5432, got %d %v", v, err)
+		t.FailNow()
+	}
+	if v := db.Sub("primary").ValueD("user", ""); v != "admin" {
+		t.Logf("expected the nested view to find admin, got %s", v)
+		t.FailNow()
+	}
+	dump := db.Dump()
+	if len(dump) != 5 || dump["url"] != "localhost:5432" || dump["primary_user"] != "admin" {
+		t.Logf("unexpected dump %v", dump)
+		t.FailNow()
+	}
+
+	var cfg struct {
+		Host string `xvals:"host"`
+		Port int    `xvals:"port"`
+	}
+	if err := db.Bind(&cfg); err != nil || cfg.Host != "localhost" || cfg.Port != 5432 {
+		t.Logf("unexpected bind %+v %v", cfg, err)
+		t.FailNow()
+	}
+
+	lib := NewContext()
+	lib.WithMap(map[string]string{"host": "default", "timeout": "5s"})
+	lib.Add("db", PriorityArgs, db)
+	GetGoodC(t, lib, "host", "localhost")
+	GetGoodC(t, lib, "timeout", "5s")
+	GetGoodC(t, lib, "url", "localhost:5432")
+
+	// the escaped reference is not expanded by lib
+	lib.WithMap(map[string]string{"user": "lib user"})
+	GetGoodC(t, lib, "note", "${user} is literal")
+	if kv, err := lib.resolvedDump(); err != nil || kv["note"] != "${user} is literal" {
+		t.Logf("unexpected note in the dump %q %v", kv["note"], err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,243 @@
+package xvals
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// watcher is a subscription to changes of a key, or of all keys with a prefix
+type watcher struct {
+	key    string
+	prefix bool
+	fn     func(key, old, new string)
+}
+
+func (w *watcher) matches(key string) bool {
+	if w.prefix {
+		return strings.HasPrefix(key, w.key)
+	}
+	return key == w.key
+}
+
+// change of a value between two reloads
+type change struct {
+	key, old, new string
+}
+
+// Watch calls fn when the value of key changes. Changes are detected by
+// ReloadObjects and Reload, which call the watchers in the reloading
+// goroutine, in the order they subscribed. A key that is added has old set to
+// "", and a key that is removed has new set to "". The returned function
+// cancels the subscription.
+func (c *Context) Watch(key string, fn func(old, new string)) (cancel func()) {
+	return c.watch(&watcher{key: c.normalize(key), fn: func(_, old, new string) { fn(old, new) }})
+}
+
+// WatchPrefix calls fn when the value of any key starting with prefix
+// changes. See Watch.
+func (c *Context) WatchPrefix(prefix string, fn func(key, old, new string)) (cancel func()) {
+	return c.watch(&watcher{key: c.normalize(prefix), prefix: true, fn: fn})
+}
+
+func (c *Context) watch(w *watcher) func() {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	if c.last == nil {
+		// Take a snapshot, so that the first reload only reports what
+		// actually changed after the subscription.
+		c.last, _ = c.resolvedDump()
+	}
+	c.watchers = append(c.watchers, w)
+	return func() {
+		c.watchMu.Lock()
+		defer c.watchMu.Unlock()
+		for i, x := range c.watchers {
+			if x == w {
+				c.watchers = append(c.watchers[:i:i], c.watchers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify compares kv with the values of the previous call and calls the
+// watchers of the changed keys.
+func (c *Context) notify(kv map[string]string) {
+	c.watchMu.Lock()
+	var changes []change
+	if c.last != nil {
+		for k, v := range kv {
+			if old, ok := c.last[k]; !ok || old != v {
+				changes = append(changes, change{k, old, v})
+			}
+		}
+		for k, old := range c.last {
+			if _, ok := kv[k]; !ok {
+				changes = append(changes, change{k, old, ""})
+			}
+		}
+	}
+	c.last = kv
+	watchers := c.watchers
+	c.watchMu.Unlock()
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].key < changes[j].key })
+	for _, ch := range changes {
+		for _, w := range watchers {
+			if w.matches(ch.key) {
+				w.fn(ch.key, ch.old, ch.new)
+			}
+		}
+	}
+}
+
+// WatchObjects calls fn for every object that is created, updated or deleted
+// when the objects of the context are reloaded. The returned function
+// cancels the subscription.
+func (c *Context) WatchObjects(fn func(ObjectEvent)) (cancel func()) {
+	return c.objects.Subscribe(fn)
+}
+
+// ReloadError is returned by Reload and Load and contains the errors of the
+// providers that failed to load, and of the objects. The providers that
+// failed keep their previous values.
+type ReloadError struct {
+	Errors []error
+}
+
+func (e *ReloadError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("failed to reload %d source(s):\n\t%s", len(e.Errors), strings.Join(msgs, "\n\t"))
+}
+
+// Unwrap returns the errors, for errors.Is and errors.As from Go 1.20.
+func (e *ReloadError) Unwrap() []error { return e.Errors }
+
+// Is reports whether any of the errors matches target, so that errors.Is
+// looks into the errors with Go versions before 1.20 too.
+func (e *ReloadError) Is(target error) bool { return anyIs(e.Errors, target) }
+
+// As finds the first of the errors that matches target, see Is.
+func (e *ReloadError) As(target interface{}) bool { return anyAs(e.Errors, target) }
+
+// Reload reloads all providers of the context, and then the objects. Watchers
+// are notified of the changed values and objects. A provider that fails to
+// reload keeps its previous values, its error is returned in a *ReloadError.
+func (c *Context) Reload() error {
+	return c.Load(context.Background())
+}
+
+// Load is Reload, but stops once ctx is done: the remaining providers are not
+// loaded, the objects are not reloaded and the error of ctx is returned in the
+// *ReloadError.
+func (c *Context) Load(ctx context.Context) error {
+	var errs []error
+	for _, p := range c.chain() {
+		if ctx.Err() != nil {
+			break
+		}
+		var err error
+		switch lp := p.(type) {
+		case LifecycleProvider:
+			err = lp.Load(ctx)
+		case Refresher:
+			err = load(ctx, lp)
+		default:
+			p.Reload()
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		if !anyIs(errs, err) {
+			errs = append(errs, err)
+		}
+		return &ReloadError{Errors: errs}
+	}
+	if err := c.ReloadObjects(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return &ReloadError{Errors: errs}
+	}
+	return nil
+}
+
+// Close closes all providers of the context that implement
+// LifecycleProvider. Returns the first error.
+func (c *Context) Close() error {
+	var first error
+	for _, p := range c.chain() {
+		if lp, ok := p.(LifecycleProvider); ok {
+			if err := lp.Close(); err != nil && first == nil {
+				first = providerError(p, err)
+			}
+		}
+	}
+	return first
+}
+
+// ProviderReport is the reload status of a provider of a context
+type ProviderReport struct {
+	ProviderInfo
+	ProviderStatus
+}
+
+// Status returns the reload status of the providers of the context, in the
+// order they are consulted. Providers that don't implement Refresher have an
+// empty ProviderStatus.
+func (c *Context) Status() []ProviderReport {
+	infos := c.Providers()
+	res := make([]ProviderReport, len(infos))
+	for i, info := range infos {
+		res[i].ProviderInfo = info
+		if r, ok := info.Provider.(Refresher); ok {
+			res[i].ProviderStatus = r.Status()
+		}
+	}
+	return res
+}
+
+// Watch calls fn when the value of key in the default context changes.
+func Watch(key string, fn func(old, new string)) (cancel func()) {
+	return defaultContext.Watch(key, fn)
+}
+
+// WatchPrefix calls fn when the value of any key starting with prefix in the
+// default context changes.
+func WatchPrefix(prefix string, fn func(key, old, new string)) (cancel func()) {
+	return defaultContext.WatchPrefix(prefix, fn)
+}
+
+// WatchObjects calls fn for every object of the default context that is
+// created, updated or deleted.
+func WatchObjects(fn func(ObjectEvent)) (cancel func()) {
+	return defaultContext.WatchObjects(fn)
+}
+
+// Reload reloads all providers of the default context, and then the objects.
+func Reload() error {
+	return defaultContext.Reload()
+}
+
+// Load reloads all providers of the default context unless ctx is done, and
+// then the objects.
+func Load(ctx context.Context) error {
+	return defaultContext.Load(ctx)
+}
+
+// Close closes the providers of the default context.
+func Close() error {
+	return defaultContext.Close()
+}
+
+// Status returns the reload status of the providers of the default context.
+func Status() []ProviderReport {
+	return defaultContext.Status()
+}
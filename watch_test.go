@@ -0,0 +1,160 @@
+package xvals
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	k := "xvals_watch_" + strings.ToLower(randString(8))
+	defer os.Unsetenv(k + "_a")
+	defer os.Unsetenv("EP_W" + k + "_ADDRESS")
+	os.Setenv(k+"_a", "1")
+
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithEnvironment()
+	c.ReloadObjects()
+
+	var changes []string
+	c.Watch(k+"_a", func(old, new string) {
+		changes = append(changes, old+">"+new)
+	})
+	cancel := c.WatchPrefix(k, func(key, old, new string) {
+		changes = append(changes, key+":"+old+">"+new)
+	})
+	var events []ObjectEvent
+	c.WatchObjects(func(ev ObjectEvent) { events = append(events, ev) })
+
+	os.Setenv(k+"_a", "2")
+	os.Setenv("EP_W"+k+"_ADDRESS", "localhost:1")
+	c.Reload()
+	if len(changes) != 2 || changes[0] != "1>2" || changes[1] != k+"_a:1>2" {
+		t.Logf("unexpected changes %v", changes)
+		t.FailNow()
+	}
+	if len(events) != 1 || events[0].Kind != ObjectCreated || events[0].Type != TpEndpoint {
+		t.Logf("unexpected events %v", events)
+		t.FailNow()
+	}
+
+	cancel()
+	changes, events = nil, nil
+	os.Unsetenv(k + "_a")
+	os.Setenv("EP_W"+k+"_ADDRESS", "localhost:2")
+	c.Reload()
+	if len(changes) != 1 || changes[0] != "2>" {
+		t.Logf("unexpected changes %v", changes)
+		t.FailNow()
+	}
+	if len(events) != 1 || events[0].Kind != ObjectUpdated || events[0].Old.(*Endpoint).Address != "localhost:1" {
+		t.Logf("unexpected events %v", events)
+		t.FailNow()
+	}
+
+	events = nil
+	os.Unsetenv("EP_W" + k + "_ADDRESS")
+	c.Reload()
+	if len(events) != 1 || events[0].Kind != ObjectDeleted {
+		t.Logf("unexpected events %v", events)
+		t.FailNow()
+	}
+}
+
+func TestReloadKeepsLastGoodValues(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	profile := filepath.Join(dir, "profile.yaml")
+	os.WriteFile(cfg, []byte("a: 1\n"), 0644)
+	os.WriteFile(profile, []byte("current_profile: p\nprofiles:\n  p:\n    b: 2\n"), 0644)
+
+	c := NewContext()
+	c.WithConfigFile(cfg)
+	c.WithProfile(profile)
+
+	os.WriteFile(cfg, []byte("a: [1\n"), 0644)
+	os.WriteFile(profile, []byte("current_profile: q\nprofiles:\n  p:\n    b: 3\n"), 0644)
+	err := c.Reload()
+	re, ok := err.(*ReloadError)
+	if !ok || len(re.Errors) != 2 {
+		t.Logf("expected a ReloadError with 2 errors, got %v", err)
+		t.FailNow()
+	}
+	GetGoodC(t, c, "a", "1")
+	GetGoodC(t, c, "b", "2")
+	for _, s := range c.Status() {
+		if s.Err == nil || s.Loaded.IsZero() || !s.Attempted.After(s.Loaded) {
+			t.Logf("unexpected status of %s %s: %+v", s.Kind, s.Source, s.ProviderStatus)
+			t.FailNow()
+		}
+	}
+
+	os.WriteFile(cfg, []byte("a: 4\n"), 0644)
+	if err := c.Reload(); err == nil || len(err.(*ReloadError).Errors) != 1 {
+		t.Logf("expected only the profile to fail, got %v", err)
+		t.FailNow()
+	}
+	GetGoodC(t, c, "a", "4")
+	for _, s := range c.Status() {
+		if (s.Err == nil) != (s.Kind == "file") {
+			t.Logf("unexpected status of %s %s: %+v", s.Kind, s.Source, s.ProviderStatus)
+			t.FailNow()
+		}
+	}
+}
+
+// counterProvider has the single key n, whose value is the counter
+type counterProvider struct {
+	n int64
+}
+
+func (p *counterProvider) Value(key string) (string, error) {
+	if key != "n" {
+		return "", ErrNotFound
+	}
+	return strconv.FormatInt(atomic.LoadInt64(&p.n), 10), nil
+}
+
+func (p *counterProvider) Dump() map[string]string {
+	return map[string]string{"n": strconv.FormatInt(atomic.LoadInt64(&p.n), 10)}
+}
+
+func (p *counterProvider) Reload() {}
+
+func TestConcurrentReloadsInOrder(t *testing.T) {
+	p := &counterProvider{}
+	c := NewContext()
+	c.Add("counter", PriorityDefaults, p)
+	var seen []int
+	c.Watch("n", func(_, v string) {
+		n, _ := strconv.Atoi(v)
+		seen = append(seen, n)
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				atomic.AddInt64(&p.n, 1)
+				c.ReloadObjects()
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 1; i < len(seen); i++ {
+		if seen[i] <= seen[i-1] {
+			t.Logf("expected the changes in order, got %d after %d", seen[i], seen[i-1])
+			t.FailNow()
+		}
+	}
+	if len(seen) == 0 || seen[len(seen)-1] != 400 {
+		t.Logf("expected the last change to be 400, got %v", seen)
+		t.FailNow()
+	}
+}
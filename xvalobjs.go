@@ -2,7 +2,10 @@ package xvals
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // Object is the interface all supported objects must implement. It is described by
@@ -28,12 +31,99 @@ type Descriptor interface {
 	Fields() []string // "ADDRESS", "TLS"
 }
 
+// A SecretDescriptor is a Descriptor with fields holding secrets, like
+// private keys. The values of those fields are redacted by RedactedDump.
+type SecretDescriptor interface {
+	Descriptor
+	SecretFields() []string // "SERVER_KEY", "CLIENT_KEY"
+}
+
 // An ObjectStore is a storage where objects described according  can be
 // stored. It uses xvals and descriptors to extract the keys/values that are used to
 // build the objects.
+//
+// An ObjectStore is safe for concurrent use. Reload builds a complete new set
+// of objects before publishing it, so readers see either the objects from
+// before or after a reload. Objects handed out by the store are never
+// modified by a later Reload, except those created with New, which are
+// updated in place so that their handles stay attached to the store.
 type ObjectStore struct {
+	mu          sync.RWMutex
 	descriptors map[string]Descriptor
-	objects     map[string]Object
+	objects     map[string]Object // never modified once published
+	created     map[string]bool   // keys of objects created with New
+	subscribers []*subscriber
+	logger      func() Logger       // logger of the context owning the store, if any
+	normalize   func(string) string // key normalizer of the context, if any
+}
+
+// key returns the key of the object typ/name, with the name normalized
+func (c *ObjectStore) key(typ, name string) string {
+	return Key(tu(typ), c.name(name))
+}
+
+// name returns the normalized name of an object
+func (c *ObjectStore) name(name string) string {
+	if c.normalize != nil {
+		name = c.normalize(name)
+	}
+	return tu(name)
+}
+
+// A loggingObject logs with the logger of the store it was created by
+type loggingObject interface {
+	setLogger(name string, l func() Logger)
+}
+
+// log returns the logger of the store, that of the context owning it
+func (c *ObjectStore) log() Logger {
+	if c.logger != nil {
+		return c.logger()
+	}
+	return defaultLogger
+}
+
+// construct creates an object of type typ named name. Must be called with
+// the lock held.
+func (c *ObjectStore) construct(d Descriptor, name string) Object {
+	obj := d.Construct()
+	if lo, ok := obj.(loggingObject); ok {
+		lo.setLogger(name, c.log)
+	}
+	return obj
+}
+
+// EventKind tells what happened to an object in an ObjectEvent
+type EventKind int
+
+// The kinds of object events
+const (
+	ObjectCreated EventKind = iota
+	ObjectUpdated
+	ObjectDeleted
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case ObjectCreated:
+		return "created"
+	case ObjectUpdated:
+		return "updated"
+	case ObjectDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// An ObjectEvent describes the change of an object during a Reload of the
+// store.
+type ObjectEvent struct {
+	Kind   EventKind
+	Type   string
+	Name   string
+	Object Object // the new object, nil if deleted
+	Old    Object // the previous object, nil if created
 }
 
 // NewObjectStore creates a new store for objects.
@@ -41,6 +131,7 @@ func NewObjectStore() *ObjectStore {
 	s := &ObjectStore{
 		descriptors: make(map[string]Descriptor),
 		objects:     make(map[string]Object),
+		created:     make(map[string]bool),
 	}
 	return s
 }
@@ -52,52 +143,228 @@ func tu(val string) string {
 
 // AddDescriptor lets the store use a new descriptor for objects.
 func (c *ObjectStore) AddDescriptor(descriptor Descriptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.descriptors[tu(descriptor.Type())] = descriptor
 }
 
-// Reload the store from the set of key/values
+// Descriptors returns the descriptors known to the store, ordered by type.
+func (c *ObjectStore) Descriptors() []Descriptor {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make([]Descriptor, 0, len(c.descriptors))
+	for _, d := range c.descriptors {
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool { return tu(res[i].Type()) < tu(res[j].Type()) })
+	return res
+}
+
+// fields returns the field names of the descriptor of typ
+func (c *ObjectStore) fields(typ string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if d, ok := c.descriptors[tu(typ)]; ok {
+		return d.Fields()
+	}
+	return nil
+}
+
+// subscriber is a function subscribed to the events of a store
+type subscriber struct {
+	fn func(ObjectEvent)
+}
+
+// Subscribe calls fn for every object that is created, updated or deleted by
+// Reload. fn is called after the new objects are published, in the goroutine
+// calling Reload. The returned function cancels the subscription.
+func (c *ObjectStore) Subscribe(fn func(ObjectEvent)) (cancel func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &subscriber{fn}
+	c.subscribers = append(c.subscribers, s)
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, x := range c.subscribers {
+			if x == s {
+				c.subscribers = append(c.subscribers[:i:i], c.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Reload the store from the set of key/values. Objects created with New are
+// kept, with the fields found in kv set on the same instance. Objects that no
+// longer have any keys in kv are removed.
 func (c *ObjectStore) Reload(kv map[string]string) {
+	c.mu.Lock()
+	before := c.createdSnapshots()
+	next := make(map[string]Object, len(c.objects))
 	for k, v := range kv {
 		typ, name, field := c.extractTypeNameField(tu(k))
 		if typ == "" {
 			// Not a field of a recognizable object
 			continue
 		}
-		var (
-			obj Object
-			ok  bool
-		)
-		if obj, ok = c.objects[Key(typ, name)]; !ok {
-			obj = c.descriptors[typ].Construct()
+		key := Key(typ, name)
+		obj, ok := next[key]
+		if !ok {
+			if obj = c.objects[key]; !c.created[key] || obj == nil {
+				obj = c.construct(c.descriptors[typ], name)
+			}
+			next[key] = obj
 		}
-		c.objects[Key(typ, name)] = obj
 		obj.Set(field, v)
 	}
+	for key := range c.created {
+		if _, ok := next[key]; !ok {
+			next[key] = c.objects[key]
+		}
+	}
+	events := objectEvents(before, next)
+	c.objects = next
+	subscribers := c.subscribers
+	c.mu.Unlock()
+
+	for _, ev := range events {
+		for _, s := range subscribers {
+			s.fn(ev)
+		}
+	}
 }
 
-// Objects returns the objects known to the store.
+// createdSnapshots returns the objects of the store, with copies in place of
+// the objects created with New, so that the changes Reload makes to them can
+// be told apart.
+func (c *ObjectStore) createdSnapshots() map[string]Object {
+	if len(c.created) == 0 {
+		return c.objects
+	}
+	res := make(map[string]Object, len(c.objects))
+	for k, v := range c.objects {
+		res[k] = v
+	}
+	for key := range c.created {
+		obj, ok := c.objects[key]
+		if !ok {
+			continue
+		}
+		typ, name := FromKey(key)
+		cp := c.construct(c.descriptors[typ], name)
+		copyFields(cp, obj)
+		res[key] = cp
+	}
+	return res
+}
+
+// objectEvents returns the events that describe the change from old to next,
+// ordered by key.
+func objectEvents(old, next map[string]Object) []ObjectEvent {
+	var events []ObjectEvent
+	for key, obj := range next {
+		typ, name := FromKey(key)
+		prev, ok := old[key]
+		switch {
+		case !ok:
+			events = append(events, ObjectEvent{Kind: ObjectCreated, Type: typ, Name: name, Object: obj})
+		case prev != obj && !sameFields(prev, obj):
+			events = append(events, ObjectEvent{Kind: ObjectUpdated, Type: typ, Name: name, Object: obj, Old: prev})
+		}
+	}
+	for key, prev := range old {
+		if _, ok := next[key]; !ok {
+			typ, name := FromKey(key)
+			events = append(events, ObjectEvent{Kind: ObjectDeleted, Type: typ, Name: name, Old: prev})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return Key(events[i].Type, events[i].Name) < Key(events[j].Type, events[j].Name)
+	})
+	return events
+}
+
+// sameFields returns true if both objects have the same fields and values
+func sameFields(a, b Object) bool {
+	fa, fb := a.Fields(), b.Fields()
+	if len(fa) != len(fb) {
+		return false
+	}
+	for k, v := range fa {
+		if w, ok := fb[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+// copyFields sets all fields of src on dst
+func copyFields(dst, src Object) {
+	if src == nil {
+		return
+	}
+	for f, v := range src.Fields() {
+		dst.Set(f, v)
+	}
+}
+
+// Objects returns the objects known to the store. The returned map is a copy
+// and can be modified by the caller.
 func (c *ObjectStore) Objects() map[string]Object {
-	return c.objects
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make(map[string]Object, len(c.objects))
+	for k, v := range c.objects {
+		res[k] = v
+	}
+	return res
 }
 
 // Get an object based on type and name
 func (c *ObjectStore) Get(typ, name string) (Object, error) {
-	key := Key(tu(typ), tu(name))
+	key := c.key(typ, name)
+	c.mu.RLock()
 	obj, ok := c.objects[key]
+	c.mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("object with key %s not found", key)
+		return nil, fmt.Errorf("object with key %s %w", key, ErrNotFound)
 	}
 	return obj, nil
 }
 
+// objectType returns the descriptor type name of objects constructed as t
+func (c *ObjectStore) objectType(t reflect.Type) (string, bool) {
+	if t.Kind() != reflect.Ptr {
+		return "", false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for typ, d := range c.descriptors {
+		if reflect.TypeOf(d.Construct()) == t {
+			return typ, true
+		}
+	}
+	return "", false
+}
+
 // New creates a new Object based on type name and object name
 func (c *ObjectStore) New(typ, name string) (Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	d, ok := c.descriptors[tu(typ)]
 	if !ok {
 		return nil, fmt.Errorf("don't know how to create an object from type %s", typ)
 	}
-	obj := d.Construct()
-	c.objects[Key(tu(typ), tu(name))] = obj
+	obj := c.construct(d, c.name(name))
+	key := c.key(typ, name)
+	next := make(map[string]Object, len(c.objects)+1)
+	for k, v := range c.objects {
+		next[k] = v
+	}
+	next[key] = obj
+	c.objects = next
+	c.created[key] = true
 	return obj, nil
 }
 
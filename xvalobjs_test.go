@@ -51,4 +51,41 @@ func TestObjects(t *testing.T) {
 		t.FailNow()
 	}
 	GetGoodObj(t, os, "to", "kalle", "phone", "1234")
+
+	// the handle stays attached to the store across reloads
+	var events []ObjectEvent
+	os.Subscribe(func(ev ObjectEvent) {
+		if ev.Name == "KALLE" {
+			events = append(events, ev)
+		}
+	})
+	os.Reload(map[string]string{"to_kalle_age": "30"})
+	if v, _ := no.Get("age"); v != "30" {
+		t.Logf("expected the reload to update the object created with New, got age %q", v)
+		t.FailNow()
+	}
+	if len(events) != 1 || events[0].Kind != ObjectUpdated || events[0].Object != no || events[0].Old.Fields()["AGE"] != "" {
+		t.Logf("expected an update of the object created with New, got %v", events)
+		t.FailNow()
+	}
+	no.Set("email", "k@example.com")
+	GetGoodObj(t, os, "to", "kalle", "email", "k@example.com")
+	GetGoodObj(t, os, "to", "kalle", "phone", "1234")
+}
+
+func TestSubscribeOrder(t *testing.T) {
+	store := NewObjectStore()
+	store.AddDescriptor(EndpointDescr)
+	var order []int
+	var cancels []func()
+	for i := 0; i < 5; i++ {
+		i := i
+		cancels = append(cancels, store.Subscribe(func(ObjectEvent) { order = append(order, i) }))
+	}
+	cancels[2]()
+	store.Reload(map[string]string{"EP_API_ADDRESS": "localhost:1"})
+	if len(order) != 4 || order[0] != 0 || order[1] != 1 || order[2] != 3 || order[3] != 4 {
+		t.Logf("expected subscription order, got %v", order)
+		t.FailNow()
+	}
 }
@@ -1,120 +1,399 @@
 package xvals
 
 import (
+	"flag"
 	"fmt"
-	"strconv"
-	"strings"
+	"io"
+	"sync"
+	"sync/atomic"
 )
 
-// ctxt contains the executable wide view of the xvals.
-var ctxt = []XvalProvider{}
+// A Context is a self contained view of the external values. It holds its own
+// chain of providers and its own ObjectStore, so that several independent
+// configurations can live in the same executable.
+//
+// Providers are consulted in order of priority, the first provider that has
+// a value for a key wins. The With methods add providers with the priorities
+// defaults < file < profile < env < args, see Priority. Providers with the
+// same priority are consulted in the order they were added.
+//
+// A Context is safe for concurrent use. The provider chain is published as an
+// immutable snapshot, so lookups never block on providers being added.
+type Context struct {
+	accessors
+	mu         sync.Mutex   // serializes changes to the provider chain
+	providers  atomic.Value // *providerChain
+	schema     atomic.Value // *Schema
+	logger     atomic.Value // loggerBox
+	normalizer atomic.Value // KeyNormalizer
+	secrets    atomic.Value // []string, patterns of secret keys
+	objects    *ObjectStore
 
-// objectStore is the default object store.
-var objectStore = NewObjectStore()
+	reloadMu sync.Mutex // serializes ReloadObjects, so that changes are seen in order
+	watchMu  sync.Mutex
+	watchers []*watcher        // in subscription order
+	last     map[string]string // values at the last reload, nil until watched
+}
+
+// NewContext creates an empty context with no providers and an empty object
+// store.
+func NewContext() *Context {
+	c := &Context{objects: NewObjectStore()}
+	c.accessors = accessors{ctx: c}
+	c.objects.logger = c.Logger
+	c.objects.normalize = c.normalize
+	return c
+}
+
+// defaultContext is the executable wide context used by the package level
+// functions.
+var defaultContext = NewContext()
+
+// Default returns the context used by the package level functions.
+func Default() *Context {
+	return defaultContext
+}
+
+// chain returns the current snapshot of the provider chain, in the order
+// the providers are consulted.
+func (c *Context) chain() []XvalProvider {
+	if pc, ok := c.providers.Load().(*providerChain); ok {
+		return pc.providers
+	}
+	return nil
+}
+
+// attach makes p, named name in the chain, log with the logger of the
+// context and normalize its keys for the context, and logs if p failed to
+// load its values.
+func (c *Context) attach(name string, p XvalProvider) {
+	if a, ok := p.(interface {
+		attachTo(*Context, XvalProvider, string)
+	}); ok {
+		a.attachTo(c, p, name)
+	}
+	if r, ok := p.(renormalizer); ok {
+		r.renormalize()
+	}
+	if r, ok := p.(Refresher); ok {
+		if err := r.Status().Err; err != nil {
+			c.Logger().Error("failed to load provider", append(logArgs(p), "error", err)...)
+		}
+	}
+}
+
+// WithArgs adds command line arguments to the context, with PriorityArgs.
+// The arguments are parsed as:
+//
+//	--key=value, --key value  sets key to value
+//	--flag                    sets flag to "true"
+//	--no-flag                 sets flag to "false"
+//	-k value                  sets the key of shorthand k, see Shorthand
+//
+// Dashes and dots in names become underscores, so --ep-api-address=host:1
+// sets ep_api_address. Arguments that are not flags are ignored, and parsing
+// stops at "--". A value starting with a dash must be given with "=", except
+// for negative numbers, so --offset -1 sets offset to -1.
+//
+// There is no declaration of which flags are booleans, so a flag followed by
+// an argument that is not a flag takes it as its value: --verbose run sets
+// verbose to "run". Write boolean flags as --verbose=true, or put them after
+// the positional arguments.
+func (c *Context) WithArgs(args []string, opts ...ArgOption) XvalProvider {
+	return c.with(PriorityArgs, newArgsProvider(args, opts...))
+}
+
+// WithFlagSet adds the flags of fs that have been set to the context, with
+// PriorityArgs. fs should be parsed before it is added, or the provider
+// reloaded after parsing.
+func (c *Context) WithFlagSet(fs *flag.FlagSet) XvalProvider {
+	return c.with(PriorityArgs, newFlagSetProvider(fs))
+}
+
+// WithEnvironment adds environmental variables to the context. All variables
+// are imported, unless the Prefix option is given.
+func (c *Context) WithEnvironment(opts ...EnvOption) XvalProvider {
+	return c.with(PriorityEnv, newEnvValProvider(opts...))
+}
+
+// WithConfigFile adds a config file to the context. More than one file can be
+// added. First added file has highest priority. Last added least priority.
+//
+// The file can be YAML, JSON or TOML, selected from the extension of the file
+// or with the Format option. Nested maps and lists are flattened into keys,
+// joined with the Separator option.
+func (c *Context) WithConfigFile(filename string, opts ...ConfigFileOption) XvalProvider {
+	p := newConfigFileProvider(filename, opts...)
+	if p == nil {
+		return nil
+	}
+	return c.with(PriorityFile, p)
+}
+
+// WithDotenv adds a .env file to the context. Returns nil if the path of the
+// file can't be resolved.
+func (c *Context) WithDotenv(filename string) XvalProvider {
+	p := newDotenvProvider(filename)
+	if p == nil {
+		return nil
+	}
+	return c.with(PriorityFile, p)
+}
+
+// WithDirectory adds a directory with one file per key to the context, like
+// a mounted Kubernetes ConfigMap or Secret. The name of a file is the key and
+// the content of the file the value. Returns nil if the path of the directory
+// can't be resolved.
+func (c *Context) WithDirectory(path string, opts ...DirectoryOption) XvalProvider {
+	p := newDirectoryProvider(path, opts...)
+	if p == nil {
+		return nil
+	}
+	return c.with(PriorityFile, p)
+}
+
+// WithProfile adds a profile file to the context.
+func (c *Context) WithProfile(profileFilePath string) XvalProvider {
+	return c.with(PriorityProfile, newProfileProvider(profileFilePath))
+}
+
+// WithMap adds a map to the context.
+func (c *Context) WithMap(src map[string]string) XvalProvider {
+	return c.with(PriorityDefaults, newMapProvider(src))
+}
+
+// lookup returns the value of key with references expanded, and the origin
+// of the value.
+func (c *Context) lookup(key string) (string, Origin, error) {
+	lcVal := c.normalize(key)
+	r, o, e := c.rawLookup(lcVal)
+	if e != nil {
+		return "", o, e
+	}
+	v, e := c.expand(r, []string{lcVal})
+	return v, o, e
+}
+
+// rawValue returns the value of the first provider that has the key, without
+// expanding any references. The default of the schema is used if no provider
+// has the key.
+func (c *Context) rawValue(key string) (string, error) {
+	r, _, e := c.rawLookup(key)
+	return r, e
+}
+
+// rawLookup is rawValue that also returns the origin of the value.
+func (c *Context) rawLookup(key string) (string, Origin, error) {
+	lcVal := c.normalize(key)
+	for i, e := range c.entries() {
+		if r, err := valueOf(e.p, lcVal); err == nil {
+			return r, origin(e, i, r), nil
+		}
+	}
+	if spec, ok := c.schemaSpec(lcVal); ok && spec.Default != "" {
+		return spec.Default, c.schemaOrigin(spec.Default), nil
+	}
+	return "", Origin{}, fmt.Errorf("key %s %w", key, ErrNotFound)
+}
+
+// schemaSpec returns the declaration of key in the schema of the context.
+// key must be normalized.
+func (c *Context) schemaSpec(key string) (KeySpec, bool) {
+	s := c.Schema()
+	if s == nil {
+		return KeySpec{}, false
+	}
+	if spec, ok := s.Lookup(key); ok {
+		return spec, true
+	}
+	for _, spec := range s.Specs() {
+		if c.normalize(spec.Name) == key {
+			return spec, true
+		}
+	}
+	return KeySpec{}, false
+}
+
+// schemaDefaults returns the defaults of the schema, with normalized keys.
+func (c *Context) schemaDefaults() map[string]string {
+	res := make(map[string]string)
+	for k, v := range c.Schema().defaults() {
+		res[c.normalize(k)] = v
+	}
+	return res
+}
+
+// Dump returns a merged set of all values available, including the defaults
+// of the schema. The values are returned as the providers have them,
+// references to other keys are not expanded.
+func (c *Context) Dump() map[string]string {
+	res := c.schemaDefaults()
+	providers := c.chain()
+	// loop backwards, so that the values of the more prioritized
+	// providers are used.
+	for i := len(providers) - 1; i >= 0; i-- {
+		for k, v := range dumpOf(providers[i]) {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// WriteDotenv writes the values of Dump to w in .env format.
+func (c *Context) WriteDotenv(w io.Writer) error {
+	return WriteDotenv(w, c.Dump())
+}
+
+// Store operations
+
+// Objects returns the objects known to the store of the context.
+func (c *Context) Objects() map[string]Object {
+	return c.objects.Objects()
+}
+
+// GetObject retrieves an object based on type and name
+func (c *Context) GetObject(typ, name string) (Object, error) {
+	return c.objects.Get(typ, name)
+}
+
+// NewObject creates a new object with the name and type and adds it to
+// the store of the context.
+func (c *Context) NewObject(typ, name string) (Object, error) {
+	return c.objects.New(typ, name)
+}
+
+// ReloadObjects reloads objects based on the current external values, with
+// references to other keys expanded. Values that fail to expand are used as
+// they are, and reported in the returned error. Watchers of changed values and
+// objects are notified. Reloads are serialized, a watcher must not reload the
+// context itself.
+func (c *Context) ReloadObjects() error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	kv, err := c.resolvedDump()
+	c.objects.Reload(kv)
+	c.notify(kv)
+	return err
+}
+
+// WithObject adds support for a specific object type.
+func (c *Context) WithObject(descr Descriptor) {
+	c.objects.AddDescriptor(descr)
+}
+
+// Package level functions operating on the default context.
+
+// WithArgs adds command line arguments to the xval context, with
+// PriorityArgs.
+func WithArgs(args []string, opts ...ArgOption) XvalProvider {
+	return defaultContext.WithArgs(args, opts...)
+}
+
+// WithFlagSet adds the flags of fs that have been set to the xval context.
+func WithFlagSet(fs *flag.FlagSet) XvalProvider {
+	return defaultContext.WithFlagSet(fs)
+}
+
+// WithEnvironment adds environmental variables to the xval context.
+func WithEnvironment(opts ...EnvOption) XvalProvider {
+	return defaultContext.WithEnvironment(opts...)
+}
+
+// WithConfigFile adds a config file to the xval context. More than one file can be added.
+// First added file has highest priority. Last added least priority.
+func WithConfigFile(filename string, opts ...ConfigFileOption) XvalProvider {
+	return defaultContext.WithConfigFile(filename, opts...)
+}
+
+// WithDotenv adds a .env file to the xval context.
+func WithDotenv(filename string) XvalProvider {
+	return defaultContext.WithDotenv(filename)
+}
+
+// WithDirectory adds a directory with one file per key to the xval context.
+func WithDirectory(path string, opts ...DirectoryOption) XvalProvider {
+	return defaultContext.WithDirectory(path, opts...)
+}
+
+// WithProfile adds a file that has a one or several profiles, which of one
+// is the current profile. Each profile is a mapProvider
+func WithProfile(profileFilePath string) XvalProvider {
+	return defaultContext.WithProfile(profileFilePath)
+}
+
+// WithMap adds a map to the xval context.
+func WithMap(src map[string]string) XvalProvider {
+	return defaultContext.WithMap(src)
+}
 
 // HasValue returns true if the value exist in the context
 func HasValue(key string) bool {
-	_, e := Value(key)
-	return e == nil
+	return defaultContext.HasValue(key)
 }
 
 // Value returns the value as a string. An error is returned
 // if the value didn't exist.
 func Value(key string) (string, error) {
-	lcVal := strings.ToLower(key)
-	for _, v := range ctxt {
-		if r, e := v.Value(lcVal); e == nil {
-			return r, nil
-		}
-	}
-	return "", fmt.Errorf("key not found %s", key)
+	return defaultContext.Value(key)
 }
 
 // ValueD retrieves a value. If it doesn't exist it will returen defaultVal
 // instead
 func ValueD(key, defaultVal string) string {
-	v, e := Value(key)
-	if e != nil {
-		return defaultVal
-	}
-	return v
+	return defaultContext.ValueD(key, defaultVal)
 }
 
 // BoolValue is a convenience function to fetch and parse
 // a value as a boolean
 func BoolValue(key string) (val bool, err error) {
-	var sv string
-	if sv, err = Value(key); err != nil {
-		return false, err
-	}
-	return strconv.ParseBool(sv)
+	return defaultContext.BoolValue(key)
 }
 
 // BoolValueD return values as bool, or return defaultVal, if it doesn't exist
 func BoolValueD(key string, defaultVal bool) bool {
-	b, e := BoolValue(key)
-	if e != nil {
-		return defaultVal
-	}
-	return b
+	return defaultContext.BoolValueD(key, defaultVal)
 }
 
 // IntValue is a convenience function to fetch and parse
 // a value as an int
 func IntValue(key string) (val int, err error) {
-	var sv string
-	if sv, err = Value(key); err != nil {
-		return 0, err
-	}
-	return strconv.Atoi(sv)
+	return defaultContext.IntValue(key)
 }
 
 // IntValueD return value as int, or defaultVal if non-existent.
 func IntValueD(key string, defaultVal int) int {
-	v, e := IntValue(key)
-	if e != nil {
-		return defaultVal
-	}
-	return v
+	return defaultContext.IntValueD(key, defaultVal)
 }
 
 // Dump returns a merged set of all values available.
 func Dump() map[string]string {
-	res := make(map[string]string)
-	// loop backwards, so that the values of the more prioritized
-	// providers are used.
-	for i := len(ctxt) - 1; i >= 0; i-- {
-		for k, v := range ctxt[i].Dump() {
-			res[k] = v
-		}
-	}
-	return res
+	return defaultContext.Dump()
 }
 
 // Store operations
 
 // Objects returns the objects known to the store.
 func Objects() map[string]Object {
-	return objectStore.Objects()
+	return defaultContext.Objects()
 }
 
 // GetObject retrieves an object based on type and name
 func GetObject(typ, name string) (Object, error) {
-	return objectStore.Get(typ, name)
+	return defaultContext.GetObject(typ, name)
 }
 
 // NewObject creates a new object with the name and type and adds it to
 // default store.
 func NewObject(typ, name string) (Object, error) {
-	return objectStore.New(typ, name)
+	return defaultContext.NewObject(typ, name)
 }
 
 // ReloadObjects reloads objects based on the current external values
-func ReloadObjects() {
-	objectStore.Reload(Dump())
+func ReloadObjects() error {
+	return defaultContext.ReloadObjects()
 }
 
 // WithObject adds support for a specific object type.
 func WithObject(descr Descriptor) {
-	objectStore.AddDescriptor(descr)
+	defaultContext.WithObject(descr)
 }
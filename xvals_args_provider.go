@@ -0,0 +1,129 @@
+package xvals
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+)
+
+// ArgOption configures how WithArgs parses the command line
+type ArgOption func(*argsProvider)
+
+// Shorthand makes -short on the command line set key, i.e.
+// Shorthand("p", "db_port") makes "-p 5432" the same as "--db-port=5432".
+func Shorthand(short, key string) ArgOption {
+	return func(p *argsProvider) {
+		p.shorts[short] = key
+	}
+}
+
+// argsProvider provides values from command line arguments
+type argsProvider struct {
+	mapProvider
+	args   []string
+	shorts map[string]string
+}
+
+// newArgsProvider creates a provider for the command line arguments.
+func newArgsProvider(args []string, opts ...ArgOption) *argsProvider {
+	p := &argsProvider{args: args, shorts: make(map[string]string)}
+	for _, o := range opts {
+		o(p)
+	}
+	p.reloadWith(p, p.reload)
+	p.Refresh()
+	return p
+}
+
+// isFlag tells whether a is a flag. Negative numbers, like -1, are values.
+func isFlag(a string) bool {
+	if !strings.HasPrefix(a, "-") || a == "-" {
+		return false
+	}
+	_, err := strconv.ParseFloat(a, 64)
+	return err != nil
+}
+
+// parseArgs parses the arguments into keys and values. Arguments that are not
+// flags are ignored, and parsing stops at "--".
+func (c *argsProvider) parseArgs() map[string]string {
+	res := make(map[string]string)
+	for i := 0; i < len(c.args); i++ {
+		a := c.args[i]
+		if a == "--" {
+			break
+		}
+		if !isFlag(a) {
+			continue
+		}
+		name, val, hasVal := a, "", false
+		if j := strings.Index(a, "="); j >= 0 {
+			name, val, hasVal = a[:j], a[j+1:], true
+		}
+		long := strings.HasPrefix(name, "--")
+		name = strings.TrimLeft(name, "-")
+		if _, err := strconv.ParseFloat(name, 64); err == nil || name == "" {
+			// not a name, like --1
+			continue
+		}
+		if key, ok := c.shorts[name]; ok && !long {
+			name = key
+		}
+		switch {
+		case hasVal:
+		case strings.HasPrefix(name, "no-"):
+			name, val = name[3:], "false"
+		case i+1 < len(c.args) && !isFlag(c.args[i+1]):
+			val = c.args[i+1]
+			i++
+		default:
+			val = "true"
+		}
+		res[flagKey(name)] = val
+	}
+	return res
+}
+
+// Kind returns "args"
+func (c *argsProvider) Kind() string { return "args" }
+
+// Source returns an empty string
+func (c *argsProvider) Source() string { return "" }
+
+// reload parses the arguments, which never fails
+func (c *argsProvider) reload() error {
+	c.store(c.parseArgs())
+	return nil
+}
+
+// flagSetProvider provides the values of the flags set in a flag.FlagSet
+type flagSetProvider struct {
+	mapProvider
+	fs *flag.FlagSet
+}
+
+// newFlagSetProvider creates a provider for the flags of fs.
+func newFlagSetProvider(fs *flag.FlagSet) *flagSetProvider {
+	p := &flagSetProvider{fs: fs}
+	p.reloadWith(p, p.reload)
+	p.Refresh()
+	return p
+}
+
+// Kind returns "flags"
+func (c *flagSetProvider) Kind() string { return "flags" }
+
+// Source returns the name of the flag set
+func (c *flagSetProvider) Source() string { return c.fs.Name() }
+
+// reload reads the flags that have been set, which never fails. Flags left
+// at their default values are not provided, so that they don't hide values
+// of other providers.
+func (c *flagSetProvider) reload() error {
+	res := make(map[string]string)
+	c.fs.Visit(func(f *flag.Flag) {
+		res[flagKey(f.Name)] = f.Value.String()
+	})
+	c.store(res)
+	return nil
+}
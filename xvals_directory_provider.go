@@ -0,0 +1,108 @@
+package xvals
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirectoryOption configures how WithDirectory reads a directory
+type DirectoryOption func(*directoryProvider)
+
+// TrimNewline removes a trailing newline from the content of the files, as
+// left by tools like echo when the files were written.
+func TrimNewline() DirectoryOption {
+	return func(p *directoryProvider) {
+		p.trimNewline = true
+	}
+}
+
+// directoryProvider provides values from a directory with one file per key,
+// like Kubernetes ConfigMaps and Secrets mounted as volumes or Docker secrets
+// in /run/secrets.
+type directoryProvider struct {
+	mapProvider
+	path        string
+	trimNewline bool
+}
+
+// newDirectoryProvider creates a provider for the directory. Returns nil if
+// the path of the directory can't be resolved.
+func newDirectoryProvider(path string, opts ...DirectoryOption) *directoryProvider {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	p := &directoryProvider{path: absPath}
+	for _, o := range opts {
+		o(p)
+	}
+	p.reloadWith(p, p.reload)
+	p.Refresh()
+	return p
+}
+
+// Kind returns "dir"
+func (c *directoryProvider) Kind() string { return "dir" }
+
+// Source returns the path of the directory
+func (c *directoryProvider) Source() string { return c.path }
+
+// dataDir returns the directory the files are read from. Kubernetes updates
+// mounted volumes by writing a new timestamped directory and then swapping
+// the ..data symlink to it. Reading from where ..data points gives a
+// consistent set of files even if the swap happens during the reload.
+func (c *directoryProvider) dataDir() string {
+	if dir, err := filepath.EvalSymlinks(filepath.Join(c.path, "..data")); err == nil {
+		return dir
+	}
+	return c.path
+}
+
+// readDir reads every file of the directory. Hidden entries, which includes
+// the .. entries used by Kubernetes, and sub directories are ignored.
+func (c *directoryProvider) readDir() (map[string]string, error) {
+	dir := c.dataDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		name := filepath.Join(dir, e.Name())
+		// Stat follows symlinks, so linked files are read and linked
+		// directories skipped
+		if stat, err := os.Stat(name); err != nil || stat.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		val := string(data)
+		if c.trimNewline {
+			val = strings.TrimSuffix(strings.TrimSuffix(val, "\n"), "\r")
+		}
+		res[e.Name()] = val
+	}
+	return res, nil
+}
+
+// reload reads the directory, and replaces the values if the whole directory
+// could be read.
+func (c *directoryProvider) reload() error {
+	vals, err := c.readDir()
+	if err != nil {
+		return err
+	}
+	c.store(vals)
+	return nil
+}
+
+// watchTarget returns the directory
+func (c *directoryProvider) watchTarget() (path string, isDir bool) {
+	return c.path, true
+}
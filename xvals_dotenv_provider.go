@@ -0,0 +1,264 @@
+package xvals
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dotenvProvider provides values from a .env file
+type dotenvProvider struct {
+	mapProvider
+	filename string
+}
+
+// newDotenvProvider creates a provider for the .env file. Returns nil if the
+// path of the file can't be resolved.
+func newDotenvProvider(filename string) *dotenvProvider {
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return nil
+	}
+	p := &dotenvProvider{filename: absPath}
+	p.reloadWith(p, p.reload)
+	p.Refresh()
+	return p
+}
+
+// Kind returns "dotenv"
+func (c *dotenvProvider) Kind() string { return "dotenv" }
+
+// Source returns the path of the .env file
+func (c *dotenvProvider) Source() string { return c.filename }
+
+// reload reads the .env file. The values are replaced only if the file could
+// be read and parsed.
+func (c *dotenvProvider) reload() error {
+	data, err := os.ReadFile(c.filename)
+	if err != nil {
+		return err
+	}
+	vals, err := parseDotenv(string(data), os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	c.store(vals)
+	return nil
+}
+
+// watchTarget returns the .env file
+func (c *dotenvProvider) watchTarget() (path string, isDir bool) {
+	return c.filename, false
+}
+
+// parseDotenv parses the content of a .env file:
+//
+//	# comment
+//	export KEY=value          # export is optional, so is the trailing comment
+//	KEY='literal value'       # single quotes may span lines, no escapes or references
+//	KEY="line1\nline2 ${VAR}" # double quotes may span lines, with escapes
+//
+// ${VAR} in unquoted and double quoted values is replaced by a key defined
+// earlier in the file, or else an environment variable found with lookup. An
+// undefined ${VAR} is kept as it is, so that it can refer to a key of another
+// provider when the value is looked up. \${VAR} in a double quoted value is
+// kept as the escaped reference $${VAR}.
+func parseDotenv(src string, lookup func(string) (string, bool)) (map[string]string, error) {
+	res := make(map[string]string)
+	// the keys defined so far, lower cased, as references ignore case
+	defined := make(map[string]string)
+	expand := func(s string) string {
+		return expandDotenv(s, func(name string) (string, bool) {
+			if v, ok := defined[strings.ToLower(name)]; ok {
+				return v, true
+			}
+			return lookup(name)
+		})
+	}
+	line := 1
+	for len(src) > 0 {
+		// skip blank lines and comments
+		trimmed := strings.TrimLeft(src, " \t\r")
+		if trimmed == "" {
+			break
+		}
+		if trimmed[0] == '\n' || trimmed[0] == '#' {
+			src = skipLine(trimmed)
+			line++
+			continue
+		}
+		src = trimmed
+		if strings.HasPrefix(src, "export ") || strings.HasPrefix(src, "export\t") {
+			src = strings.TrimLeft(src[len("export"):], " \t")
+		}
+		eq := strings.IndexAny(src, "=\n")
+		if eq < 0 || src[eq] != '=' {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", line)
+		}
+		key := strings.TrimSpace(src[:eq])
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: invalid key %q", line, key)
+		}
+		src = strings.TrimLeft(src[eq+1:], " \t")
+
+		var val string
+		switch {
+		case strings.HasPrefix(src, "'"):
+			end := strings.Index(src[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quoted value of %s", line, key)
+			}
+			// literal, references are escaped so they are never expanded
+			val = strings.ReplaceAll(src[1:end+1], "${", "$${")
+			src = src[end+2:]
+		case strings.HasPrefix(src, `"`):
+			var rest string
+			var err error
+			val, rest, err = unquoteDotenv(src[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v of %s", line, err, key)
+			}
+			val = expand(val)
+			src = rest
+		default:
+			end := strings.IndexByte(src, '\n')
+			if end < 0 {
+				end = len(src)
+			}
+			val = src[:end]
+			if i := strings.Index(val, " #"); i >= 0 {
+				val = val[:i]
+			}
+			val = expand(strings.TrimSpace(val))
+			src = src[end:]
+		}
+		line += strings.Count(val, "\n")
+		// only a comment may follow the value
+		rest := strings.TrimLeft(src, " \t\r")
+		if rest != "" && rest[0] != '\n' && rest[0] != '#' {
+			return nil, fmt.Errorf("line %d: unexpected content after value of %s", line, key)
+		}
+		src = skipLine(rest)
+		line++
+		res[key] = val
+		defined[strings.ToLower(key)] = val
+	}
+	return res, nil
+}
+
+// skipLine returns s after the first newline, or "" if there is none.
+func skipLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return ""
+}
+
+// unquoteDotenv reads a double quoted value, s starts after the opening
+// quote. Returns the value and the content after the closing quote.
+func unquoteDotenv(s string) (val, rest string, err error) {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				break
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '\n':
+				// escaped line break continues the value on the next line
+			case '$':
+				if i+1 < len(s) && s[i+1] == '{' {
+					// escaped reference, see Context.Value
+					b.WriteByte('$')
+				}
+				b.WriteByte('$')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated double quoted value")
+}
+
+// expandDotenv replaces ${NAME} in s with the value from resolve. References
+// that can't be resolved and escaped references, $${NAME}, are kept.
+func expandDotenv(s string, resolve func(string) (string, bool)) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	b := &strings.Builder{}
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("$${")
+			i += 3
+			continue
+		}
+		if strings.HasPrefix(s[i:], "${") {
+			if end := strings.IndexByte(s[i:], '}'); end > 0 {
+				if v, ok := resolve(s[i+2 : i+end]); ok {
+					b.WriteString(v)
+					i += end + 1
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// WriteDotenv writes kv to w in .env format, ordered by key. Keys are written
+// in upper case and values are quoted when needed, so that the output can be
+// read back by WithDotenv. References to other keys, ${key}, are written as
+// they are.
+func WriteDotenv(w io.Writer, kv map[string]string) error {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := writeDotenvLine(w, k, kv[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDotenvLine writes one KEY=VALUE line
+func writeDotenvLine(w io.Writer, key, val string) error {
+	_, err := fmt.Fprintf(w, "%s=%s\n", strings.ToUpper(key), quoteDotenv(val))
+	return err
+}
+
+// quoteDotenv returns val as it should be written in a .env file
+func quoteDotenv(val string) string {
+	safe := true
+	for _, r := range val {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.,/:@+=%${}", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return val
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(val) + `"`
+}
@@ -1,8 +1,9 @@
 package xvals
 
 import (
-	"log"
+	"fmt"
 	"os"
+	"sync/atomic"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,32 +17,49 @@ type ProfileFile struct {
 type profileProvider struct {
 	mapProvider
 	filename string
+	current  atomic.Value // string, name of the loaded profile
 }
 
-// WithProfile adds a file that has a one or several profiles, which of one
-// is the current profile. Each profile is a mapProvider
-func WithProfile(profileFilePath string) XvalProvider {
+// newProfileProvider creates a provider for a file that has one or several
+// profiles, which of one is the current profile. Each profile is a mapProvider
+func newProfileProvider(profileFilePath string) *profileProvider {
 	p := &profileProvider{filename: profileFilePath}
-	p.Reload()
-	ctxt = append(ctxt, p)
+	p.reloadWith(p, p.reload)
+	p.Refresh()
 	return p
 }
 
-// Reload the profile file
-func (c *profileProvider) Reload() {
+// Kind returns "profile"
+func (c *profileProvider) Kind() string { return "profile" }
+
+// Source returns the path of the profile file and the name of the current
+// profile as <path>#<profile>
+func (c *profileProvider) Source() string {
+	profile, _ := c.current.Load().(string)
+	return fmt.Sprintf("%s#%s", c.filename, profile)
+}
+
+// reload the profile file. The current profile is replaced only if the file
+// could be read and parsed, and the profile exists in it.
+func (c *profileProvider) reload() error {
 	data, err := os.ReadFile(c.filename)
 	if err != nil {
-		log.Printf("failed to load file %s %v", c.filename, err)
+		return err
 	}
 	content := &ProfileFile{}
-	err = yaml.Unmarshal(data, content)
-	if err != nil {
-		log.Printf("failed to parse file %s %v", c.filename, err)
+	if err = yaml.Unmarshal(data, content); err != nil {
+		return err
 	}
 	cp, ok := content.Profiles[content.CurrentProfile]
-	if ok {
-		c.mapProvider = mapProvider{vals: cp}
-		return
+	if !ok {
+		return fmt.Errorf("current profile %s is not available in profile file %s", content.CurrentProfile, c.filename)
 	}
-	log.Printf("current profile %s is not available in profile file %s", content.CurrentProfile, c.filename)
+	c.current.Store(content.CurrentProfile)
+	c.store(cp)
+	return nil
+}
+
+// watchTarget returns the profile file
+func (c *profileProvider) watchTarget() (path string, isDir bool) {
+	return c.filename, false
 }
@@ -1,135 +1,358 @@
 package xvals
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"gopkg.in/yaml.v3"
+	"sync"
+	"time"
 )
 
-// XvalProvider is the interface all providers of values must implement
+// XvalProvider is the interface all providers of values must implement.
+//
+// Providers must be safe for concurrent use. Value and Dump may be called
+// while Reload is running and must then either see the old or the new set of
+// values, never a mix of them.
 type XvalProvider interface {
 
 	// Get Value or error
 	Value(key string) (val string, err error)
 
-	// Dump all values from the source
+	// Dump all values from the source. The returned map is a snapshot and
+	// must not be modified.
 	Dump() map[string]string
 
 	// Reload the values from the source
-	// In case of an error, the variables will simply not be available
+	// In case of an error, the previous values are kept
 	// An error message should be logged in that case.
 	Reload()
 }
 
-// WithEnvironment adds environmental variables to the xval context.
-// Will panic in case of errors
-func WithEnvironment() XvalProvider {
+// A chainProvider is a provider whose Value and Dump are not the values a
+// context should expand, like a View, whose values are already expanded. The
+// context uses chainValue and chainDump instead.
+type chainProvider interface {
+	chainValue(key string) (string, error)
+	chainDump() map[string]string
+}
+
+// valueOf returns the value of key in p, as the context should expand it
+func valueOf(p XvalProvider, key string) (string, error) {
+	if cp, ok := p.(chainProvider); ok {
+		return cp.chainValue(key)
+	}
+	return p.Value(key)
+}
+
+// dumpOf returns the values of p, as the context should expand them
+func dumpOf(p XvalProvider) map[string]string {
+	if cp, ok := p.(chainProvider); ok {
+		return cp.chainDump()
+	}
+	return p.Dump()
+}
+
+// A Refresher is a provider that reports the outcome of reloading its
+// values. All providers of this package implement it.
+type Refresher interface {
+	XvalProvider
+
+	// Refresh reloads the values from the source. The new values replace
+	// the previous values only if the source could be completely read,
+	// parsed and validated. Otherwise the previous values are kept and the
+	// error is returned.
+	Refresh() error
+
+	// Status returns the outcome of the latest reload
+	Status() ProviderStatus
+}
+
+// ProviderStatus is the outcome of the latest reload of a provider
+type ProviderStatus struct {
+	Loaded    time.Time // when values were last loaded, zero if never
+	Attempted time.Time // when a reload was last attempted
+	Err       error     // error of the last attempt, nil if it succeeded
+}
+
+// reloadStatus keeps track of the reloads of a provider
+type reloadStatus struct {
+	mu     sync.Mutex
+	status ProviderStatus
+}
+
+// record the outcome of a reload. Returns err.
+func (s *reloadStatus) record(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.status.Attempted = now
+	s.status.Err = err
+	if err == nil {
+		s.status.Loaded = now
+	}
+	return err
+}
+
+// Status returns the outcome of the latest reload
+func (s *reloadStatus) Status() ProviderStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// A DescribedProvider can tell what kind of source its values come from and
+// where that source is. All providers of this package implement it.
+type DescribedProvider interface {
+	// Kind of provider, like "env", "file", "profile" or "map"
+	Kind() string
+
+	// Source of the values, like the path of a file. Empty if the kind of
+	// provider is enough to identify the source.
+	Source() string
+}
+
+// describe returns the kind and source of p. Providers not implementing
+// DescribedProvider are described by their go type.
+func describe(p XvalProvider) (kind, source string) {
+	if d, ok := p.(DescribedProvider); ok {
+		return d.Kind(), d.Source()
+	}
+	return fmt.Sprintf("%T", p), ""
+}
+
+// EnvOption configures the environment provider
+type EnvOption func(*envValProvider)
+
+// Prefix only imports the environment variables whose name starts with
+// prefix, ignoring case, like Prefix("MYAPP_").
+func Prefix(prefix string) EnvOption {
+	return func(p *envValProvider) {
+		p.prefix = prefix
+	}
+}
+
+// StripPrefix removes the prefix set by Prefix from the keys, so that
+// MYAPP_DB_HOST becomes the key db_host.
+func StripPrefix() EnvOption {
+	return func(p *envValProvider) {
+		p.strip = true
+	}
+}
+
+// newEnvValProvider creates a provider loaded with the current environment.
+func newEnvValProvider(opts ...EnvOption) *envValProvider {
 	p := &envValProvider{}
-	p.Reload()
-	ctxt = append(ctxt, p)
+	for _, o := range opts {
+		o(p)
+	}
+	p.reloadWith(p, p.reload)
+	p.Refresh()
 	return p
 }
 
 // EnvValProvider provides values from the environment variables
 type envValProvider struct {
 	mapProvider
+	prefix string
+	strip  bool
 }
 
-func (c *envValProvider) Reload() {
+// Kind returns "env"
+func (c *envValProvider) Kind() string { return "env" }
+
+// Source returns the prefix of the imported variables as <prefix>*, or an
+// empty string if all variables are imported.
+func (c *envValProvider) Source() string {
+	if c.prefix == "" {
+		return ""
+	}
+	return c.prefix + "*"
+}
+
+// reload reads the environment variables, which never fails
+func (c *envValProvider) reload() error {
+	c.store(parseEnviron(os.Environ(), c.prefix, c.strip))
+	return nil
+}
+
+// parseEnviron returns the variables of environ, in the format of
+// os.Environ, whose name starts with prefix. The value is everything after
+// the first '='. The prefix is matched ignoring case, names are kept as they
+// are.
+func parseEnviron(environ []string, prefix string, strip bool) map[string]string {
+	lcPrefix := strings.ToLower(prefix)
 	res := make(map[string]string)
-	for _, v := range os.Environ() {
-		s := strings.Split(v, "=")
-		if len(s) == 1 {
-			res[strings.ToLower(s[0])] = ""
-		} else if len(s) > 1 {
-			res[strings.ToLower(s[0])] = strings.Join(s[1:], "")
+	for _, v := range environ {
+		name, val := v, ""
+		if i := strings.IndexByte(v, '='); i >= 0 {
+			name, val = v[:i], v[i+1:]
+		}
+		if !strings.HasPrefix(strings.ToLower(name), lcPrefix) {
+			continue
+		}
+		key := name
+		if strip {
+			key = key[len(prefix):]
+		}
+		if key == "" {
+			continue
 		}
+		res[key] = val
 	}
-	c.vals = res
+	return res
 }
 
-// WithConfigFile adds a config file to the xval context. More than one file can be added.
-// First added file has highest priority. Last added least priority.
-func WithConfigFile(filename string) XvalProvider {
+// newConfigFileProvider creates a provider for the config file. Returns nil
+// if the path of the file can't be resolved.
+func newConfigFileProvider(filename string, opts ...ConfigFileOption) *configFileProvider {
 	absPath, err := filepath.Abs(filename)
 	if err != nil {
 		return nil
 	}
-	c := &configFileProvider{filename: absPath}
-	c.Reload()
-	ctxt = append(ctxt, c)
+	c := &configFileProvider{filename: absPath, sep: "_"}
+	for _, o := range opts {
+		o(c)
+	}
+	if c.format == FormatAuto {
+		c.format = formatOf(absPath)
+	}
+	c.reloadWith(c, c.reload)
+	c.Refresh()
 	return c
 }
 
-// CfgFile is the structure of files the ConfigFileProvider uses.
+// CfgFile is the structure of files the ConfigFileProvider used.
+//
+// Deprecated: config files are flattened into keys and no longer decoded
+// into CfgFile, which is kept for compatibility only.
 type CfgFile struct {
 	Values map[string]string `yaml:"values,inline"`
 }
 
-// A configFileProvider provides values from a configuration file.
+// A configFileProvider provides values from a configuration file. Nested
+// maps and lists in the file are flattened into keys.
 type configFileProvider struct {
+	mapProvider
 	filename string
-	ctx      *CfgFile
+	format   ConfigFormat
+	sep      string
 }
 
-func (c *configFileProvider) readFile() error {
+// reload reads the config file. The values are replaced only if the file
+// could be read and parsed.
+func (c *configFileProvider) reload() error {
 	d, e := os.ReadFile(c.filename)
 	if e != nil {
 		return e
 	}
-	vals := make(map[string]string)
-	c.ctx = &CfgFile{Values: make(map[string]string)}
-	e = yaml.Unmarshal(d, &vals)
-	for k, v := range vals {
-		c.ctx.Values[strings.ToLower(k)] = v
+	vals, e := decodeConfig(d, c.format, c.sep)
+	if e != nil {
+		return e
 	}
-	return e
+	c.store(vals)
+	return nil
 }
 
 func (c *configFileProvider) Value(key string) (val string, err error) {
-	if v, ok := c.ctx.Values[key]; ok {
+	if v, ok := c.load()[key]; ok {
 		return v, nil
 	}
-	return "", fmt.Errorf("failed to retrieve key %s from config file %s", key, c.filename)
+	return "", fmt.Errorf("failed to retrieve key %s from config file %s: %w", key, c.filename, ErrNotFound)
 }
 
-func (c *configFileProvider) Dump() map[string]string {
-	return c.ctx.Values
-}
+// Kind returns "file"
+func (c *configFileProvider) Kind() string { return "file" }
 
-func (c *configFileProvider) Reload() {
-	e := c.readFile()
-	if e != nil {
-		log.Printf("failed to reload configFileProvider %v", e)
-	}
+// Source returns the path of the config file
+func (c *configFileProvider) Source() string { return c.filename }
+
+// watchTarget returns the config file
+func (c *configFileProvider) watchTarget() (path string, isDir bool) {
+	return c.filename, false
 }
 
-// WithMap adds a map to the xval context.
-func WithMap(src map[string]string) XvalProvider {
-	p := &mapProvider{vals: src}
-	ctxt = append(ctxt, p)
+// newMapProvider creates a provider for the values of src. The map is copied,
+// later changes to src are not seen by the provider.
+func newMapProvider(src map[string]string) *mapProvider {
+	vals := make(map[string]string, len(src))
+	for k, v := range src {
+		vals[k] = v
+	}
+	p := &mapProvider{}
+	p.store(vals)
+	p.record(nil)
 	return p
 }
 
 // mapProvider provides values from a map
 type mapProvider struct {
-	vals map[string]string
+	reloadStatus
+	normalizedValues
+	self XvalProvider // the provider embedding the mapProvider, if any
+	read func() error // reads the values from the source, nil for a map
+}
+
+// reloadWith makes Reload, Refresh and Load read the values with read. self
+// is the provider embedding the mapProvider, used to describe it in logs and
+// errors.
+func (c *mapProvider) reloadWith(self XvalProvider, read func() error) {
+	c.self, c.read = self, read
+}
+
+// provider returns the provider embedding the mapProvider
+func (c *mapProvider) provider() XvalProvider {
+	if c.self != nil {
+		return c.self
+	}
+	return c
 }
 
 func (c *mapProvider) Value(key string) (value string, err error) {
-	if v, ok := c.vals[key]; ok {
+	if v, ok := c.load()[key]; ok {
 		return v, nil
 	}
-	return "", fmt.Errorf("failed to retrieve key %s from src map", key)
+	return "", fmt.Errorf("failed to retrieve key %s from src map: %w", key, ErrNotFound)
 }
 
 func (c *mapProvider) Dump() map[string]string {
-	return c.vals
+	return c.load()
 }
 
+// Kind returns "map"
+func (c *mapProvider) Kind() string { return "map" }
+
+// Source returns an empty string
+func (c *mapProvider) Source() string { return "" }
+
+// Reload reads the values from the source again, keeping the previous values
+// and logging the error on failure.
 func (c *mapProvider) Reload() {
+	if err := c.Refresh(); err != nil {
+		logReloadError(c.log(), c.provider(), err)
+	}
+}
+
+// Refresh reads the values from the source again, keeping the previous
+// values on errors. The values of a map never change.
+func (c *mapProvider) Refresh() error {
+	if c.read == nil {
+		return c.record(nil)
+	}
+	return c.record(c.read())
+}
+
+// Load reads the values from the source unless ctx is done
+func (c *mapProvider) Load(ctx context.Context) error {
+	r, ok := c.provider().(Refresher)
+	if !ok {
+		r = c
+	}
+	return load(ctx, r)
+}
+
+// Close does nothing
+func (c *mapProvider) Close() error {
+	return nil
 }
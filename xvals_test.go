@@ -1,8 +1,12 @@
 package xvals
 
 import (
+	"bytes"
+	"flag"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -84,11 +88,12 @@ func GetBadBool(t *testing.T, key string) {
 }
 func TestWithMap(t *testing.T) {
 
-	WithMap(Fixture)
+	defer removeProvider(t, WithMap(Fixture))
 	GetGood(t, "name", "John")
 	GetGood(t, "phone", "12345")
 	GetGoodInt(t, "phone", 12345)
-	GetBadBool(t, "home")
+	GetGoodBool(t, "home", true)
+	GetBadBool(t, "name")
 	GetGoodBool(t, "not-home", false)
 	GetBad(t, "lastname")
 }
@@ -101,6 +106,7 @@ func TestWithEnvVars(t *testing.T) {
 	os.Setenv(rk, rv)
 	GetBad(t, rk)
 	r := WithEnvironment()
+	defer removeProvider(t, r)
 	GetGood(t, rk, rv)
 
 	os.Unsetenv(rk)
@@ -110,9 +116,35 @@ func TestWithEnvVars(t *testing.T) {
 	GetBad(t, rk)
 }
 
+func TestEnvironmentPrefix(t *testing.T) {
+	prefix := "XVALS_" + strings.ToUpper(randString(6)) + "_"
+	defer os.Unsetenv(prefix + "PEM")
+	defer os.Unsetenv(prefix + "URL")
+	os.Setenv(prefix+"PEM", "aGVsbG8=")
+	os.Setenv(prefix+"URL", "http://host/?a=1&b=2")
+
+	c := NewContext()
+	c.WithEnvironment(Prefix(prefix))
+	GetGoodC(t, c, prefix+"pem", "aGVsbG8=")
+	GetGoodC(t, c, prefix+"url", "http://host/?a=1&b=2")
+	if len(c.Dump()) != 2 {
+		t.Logf("expected only the prefixed variables, got %v", c.Dump())
+		t.FailNow()
+	}
+
+	s := NewContext()
+	s.WithEnvironment(Prefix(strings.ToLower(prefix)), StripPrefix())
+	GetGoodC(t, s, "pem", "aGVsbG8=")
+	if s.HasValue(prefix + "pem") {
+		t.Logf("expected the prefix to be stripped")
+		t.FailNow()
+	}
+}
+
 func TestWithConfigFile(t *testing.T) {
 	GetBad(t, "ep_staffan_address")
 	p := WithConfigFile("testdata/testctx1.yaml")
+	defer removeProvider(t, p)
 	GetGood(t, "ep_staffan_address", "localhost:12345")
 	p.Reload()
 	GetGood(t, "ep_staffan_address", "localhost:12345")
@@ -121,6 +153,7 @@ func TestWithConfigFile(t *testing.T) {
 func TestWithProfile(t *testing.T) {
 	GetBad(t, "key1")
 	p := WithProfile("testdata/testprofiles.yaml")
+	defer removeProvider(t, p)
 	GetGood(t, "key1", "val1")
 	p.Reload()
 	GetGood(t, "key2", "val2")
@@ -142,3 +175,237 @@ func TestCreateProfileFile(t *testing.T) {
 	}
 	os.WriteFile("testdata/testprofiles.yaml", data, os.ModePerm)
 }
+
+func TestIndependentContexts(t *testing.T) {
+	c1 := NewContext()
+	c2 := NewContext()
+	c1.WithMap(map[string]string{"name": "John"})
+	c2.WithMap(map[string]string{"name": "Lisa"})
+
+	if v, e := c1.Value("name"); e != nil || v != "John" {
+		t.Logf("c1 got:[%s] [%v] expected: [John]", v, e)
+		t.FailNow()
+	}
+	if v, e := c2.Value("name"); e != nil || v != "Lisa" {
+		t.Logf("c2 got:[%s] [%v] expected: [Lisa]", v, e)
+		t.FailNow()
+	}
+
+	c1.WithObject(EndpointDescr)
+	c1.WithMap(map[string]string{"ep_api_address": "localhost:1"})
+	c1.ReloadObjects()
+	if _, e := c1.GetEndpoint("api"); e != nil {
+		t.Logf("c1 expected endpoint api, got %v", e)
+		t.FailNow()
+	}
+	if _, e := c2.GetEndpoint("api"); e == nil {
+		t.Logf("c2 expected no endpoint api")
+		t.FailNow()
+	}
+}
+
+func TestConcurrentReload(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	p := c.WithProfile("testdata/testprofiles.yaml")
+	c.WithMap(map[string]string{"ep_api_address": "localhost:1"})
+	c.ReloadObjects()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			p.Reload()
+			c.ReloadObjects()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if v, e := c.Value("key1"); e != nil || v != "val1" {
+			t.Logf("key: [key1] got:[%s] [%v] expected: [val1]", v, e)
+			t.FailNow()
+		}
+		if _, e := c.GetEndpoint("api"); e != nil {
+			t.Logf("expected endpoint api, got %v", e)
+			t.FailNow()
+		}
+		c.Dump()
+		c.Objects()
+	}
+	<-done
+}
+
+func TestWithArgs(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithMap(map[string]string{"name": "John", "verbose": "false"})
+	c.WithArgs([]string{
+		"--name=Lisa", "--db-port", "5432", "-v", "--no-color",
+		"-p", "8080", "positional", "--offset", "-1", "--ratio=-0.5", "-2",
+		"--ep-api-address=host:1", "--", "--ignored",
+	}, Shorthand("p", "port"))
+
+	tests := map[string]string{
+		"name":    "Lisa",
+		"db_port": "5432",
+		"v":       "true",
+		"color":   "false",
+		"port":    "8080",
+		"offset":  "-1",
+		"ratio":   "-0.5",
+	}
+	for k, exp := range tests {
+		if v, e := c.Value(k); e != nil || v != exp {
+			t.Logf("key: [%s] got:[%s] [%v] expected: [%s]", k, v, e, exp)
+			t.FailNow()
+		}
+	}
+	if c.HasValue("ignored") || c.HasValue("positional") || c.HasValue("1") || c.HasValue("2") {
+		t.Logf("expected arguments after -- and positionals to be ignored")
+		t.FailNow()
+	}
+	c.ReloadObjects()
+	if ep, e := c.GetEndpoint("api"); e != nil || ep.Address != "host:1" {
+		t.Logf("unexpected endpoint %v %v", ep, e)
+		t.FailNow()
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("verbose", "false", "")
+	fs.String("name", "unset", "")
+	fs.Parse([]string{"-verbose=true"})
+	c.WithFlagSet(fs)
+	GetGoodC(t, c, "verbose", "true")
+	GetGoodC(t, c, "name", "Lisa")
+}
+
+func GetGoodC(t *testing.T, c *Context, key, exp string) {
+	v, e := c.Value(key)
+	if e != nil {
+		t.Logf("key: [%s] got:[%v] expected: [nil]", key, e)
+		t.FailNow()
+	}
+	if v != exp {
+		t.Logf("key: [%s] got:[%s] expected: [%s]", key, v, exp)
+		t.FailNow()
+	}
+}
+
+func TestWithDotenv(t *testing.T) {
+	c := NewContext()
+	c.WithObject(EndpointDescr)
+	c.WithDotenv("testdata/test.env")
+	c.WithMap(map[string]string{"not_in_file": "from map"})
+
+	tests := map[string]string{
+		"api_host": "localhost",
+		"api_port": "8080",
+		"api_url":  "http://localhost:8080/",
+		"greeting": "hello\nworld",
+		"literal":  "${API_HOST} stays",
+		"escaped":  "${API_HOST} is a reference",
+		"unknown":  "from map",
+	}
+	for k, exp := range tests {
+		GetGoodC(t, c, k, exp)
+	}
+	c.ReloadObjects()
+	ep, e := c.GetEndpoint("api")
+	if e != nil || !strings.HasPrefix(ep.ServerCert, "-----BEGIN CERTIFICATE-----\nMIIB") {
+		t.Logf("unexpected endpoint %v %v", ep, e)
+		t.FailNow()
+	}
+
+	// what is written can be read back
+	b := &bytes.Buffer{}
+	ep.Write("copy", b)
+	c.WriteDotenv(b)
+	raw, e := parseDotenv(b.String(), func(string) (string, bool) { return "", false })
+	if e != nil {
+		t.Logf("failed to parse written .env %v\n%s", e, b)
+		t.FailNow()
+	}
+	vals := normalizeKeys(raw, LooseKeys, nil)
+	if vals["ep_copy_server_cert"] != ep.ServerCert || vals["greeting"] != "hello\nworld" {
+		t.Logf("unexpected values read back %v", vals)
+		t.FailNow()
+	}
+	if vals["literal"] != "$${API_HOST} stays" {
+		t.Logf("unexpected literal read back %q", vals["literal"])
+		t.FailNow()
+	}
+}
+
+func TestNestedConfigFiles(t *testing.T) {
+	for _, f := range []string{"testdata/nested.yaml", "testdata/nested.json", "testdata/nested.toml"} {
+		c := NewContext()
+		c.WithObject(EndpointDescr)
+		c.WithConfigFile(f)
+		c.ReloadObjects()
+		GetGoodC(t, c, "servers_1_host", "two")
+		GetGoodC(t, c, "servers_0_port", "1")
+		GetGoodC(t, c, "debug", "true")
+		if ep, e := c.GetEndpoint("api"); e != nil || ep.Address != "localhost:1" || ep.TLS != "none" {
+			t.Logf("%s: unexpected endpoint %v %v", f, ep, e)
+			t.FailNow()
+		}
+	}
+
+	c := NewContext()
+	c.WithConfigFile("testdata/nested.conf", Format(FormatJSON), Separator("."))
+	GetGoodC(t, c, "ep.api.address", "localhost:1")
+}
+
+func TestJSONNumbers(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "numbers.json")
+	os.WriteFile(f, []byte(`{"id": 12345678901234567891, "ratio": 0.25, "count": 10}`), 0o644)
+	c := NewContext()
+	c.WithConfigFile(f)
+	GetGoodC(t, c, "id", "12345678901234567891")
+	GetGoodC(t, c, "ratio", "0.25")
+	GetGoodC(t, c, "count", "10")
+}
+
+// writeConfigMap writes files as Kubernetes does for a mounted ConfigMap, in a
+// new timestamped directory that ..data is swapped to.
+func writeConfigMap(t *testing.T, dir, version string, files map[string]string) {
+	data := filepath.Join(dir, "..."+version)
+	if e := os.Mkdir(data, 0o755); e != nil {
+		t.Fatal(e)
+	}
+	for k, v := range files {
+		if e := os.WriteFile(filepath.Join(data, k), []byte(v), 0o644); e != nil {
+			t.Fatal(e)
+		}
+		os.Symlink(filepath.Join("..data", k), filepath.Join(dir, k))
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	if e := os.Symlink(filepath.Base(data), tmp); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.Rename(tmp, filepath.Join(dir, "..data")); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestWithDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigMap(t, dir, "1", map[string]string{"db_host": "one\n", "DB_PASSWORD": "secret"})
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("x"), 0o644)
+
+	c := NewContext()
+	p := c.WithDirectory(dir, TrimNewline())
+	GetGoodC(t, c, "db_host", "one")
+	GetGoodC(t, c, "db_password", "secret")
+	if c.HasValue(".hidden") || c.HasValue("..data") {
+		t.Logf("expected hidden entries to be ignored")
+		t.FailNow()
+	}
+
+	writeConfigMap(t, dir, "2", map[string]string{"db_host": "two\n", "DB_PASSWORD": "secret"})
+	p.Reload()
+	GetGoodC(t, c, "db_host", "two")
+
+	raw := NewContext()
+	raw.WithDirectory(dir)
+	GetGoodC(t, raw, "db_host", "two\n")
+}